@@ -76,28 +76,78 @@ func (r RecordCallsRequest) toProto() *pb.RecordCallsRequest {
 }
 
 type Call struct {
-	Dependency string
-	Duration   time.Duration
-	Error      error
-	Name       string
-	StartedAt  time.Time
+	Attempts       []Attempt
+	AttemptTimeout time.Duration
+	Cached         bool
+	Categories     []string
+	Coalesced      uint
+	Dependency     string
+	Duration       time.Duration
+	Error          error
+	Fallback       string
+	Fault          string
+	Hedge          uint
+	Labels         map[string]string
+	Name           string
+	StartedAt      time.Time
+	Timeout        time.Duration
+	WouldReject    []string
 }
 
 func (c Call) toProto() *pb.Call {
-	var err string
-	if c.Error != nil {
-		err = c.Error.Error()
+	var protoAttempts []*pb.Attempt
+	if len(c.Attempts) > 0 {
+		protoAttempts = make([]*pb.Attempt, len(c.Attempts))
+		for i, attempt := range c.Attempts {
+			protoAttempts[i] = attempt.toProto()
+		}
 	}
 
 	return &pb.Call{
-		Dependency: c.Dependency,
-		Duration:   durationpb.New(c.Duration),
-		Error:      err,
-		Name:       c.Name,
-		StartedAt:  timestamppb.New(c.StartedAt),
+		Attempts:       protoAttempts,
+		AttemptTimeout: durationpb.New(c.AttemptTimeout),
+		Cached:         c.Cached,
+		Categories:     c.Categories,
+		Coalesced:      uint32(c.Coalesced),
+		Dependency:     c.Dependency,
+		Duration:       durationpb.New(c.Duration),
+		Error:          errorString(c.Error),
+		Fallback:       c.Fallback,
+		Fault:          c.Fault,
+		Hedge:          uint32(c.Hedge),
+		Labels:         c.Labels,
+		Name:           c.Name,
+		StartedAt:      timestamppb.New(c.StartedAt),
+		Timeout:        durationpb.New(c.Timeout),
+		WouldReject:    c.WouldReject,
 	}
 }
 
+// Attempt is a single attempt made to complete a call. A call makes
+// multiple attempts when it is retried or hedged.
+type Attempt struct {
+	Duration  time.Duration
+	Error     error
+	Hedge     uint
+	StartedAt time.Time
+}
+
+func (a Attempt) toProto() *pb.Attempt {
+	return &pb.Attempt{
+		Duration:  durationpb.New(a.Duration),
+		Error:     errorString(a.Error),
+		Hedge:     uint32(a.Hedge),
+		StartedAt: timestamppb.New(a.StartedAt),
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
 func (c *client) RecordCalls(ctx context.Context, req RecordCallsRequest) error {
 	if c == nil || len(req.Calls) == 0 {
 		return nil
@@ -45,18 +45,39 @@ func (s *ClientTestSuite) TestRecordCalls() {
 	req := errcatapi.RecordCallsRequest{
 		Calls: []errcatapi.Call{
 			{
+				Categories: []string{"server_error"},
+				Coalesced:  4,
 				Dependency: "mysql",
 				Duration:   time.Duration(60) * time.Second,
 				Error:      errors.New("oops"),
+				Labels:     map[string]string{"shard": "3", "table": "orders"},
 				Name:       "orders.Purchase",
 				StartedAt:  time.Now(),
+				Timeout:    time.Duration(90) * time.Second,
 			},
 			{
-				Dependency: "google",
-				Duration:   time.Duration(120) * time.Second,
-				Error:      nil,
-				Name:       "google.Search",
-				StartedAt:  time.Now(),
+				Attempts: []errcatapi.Attempt{
+					{
+						Duration:  time.Duration(100) * time.Second,
+						Error:     errors.New("oops"),
+						StartedAt: time.Now(),
+					},
+					{
+						Duration:  time.Duration(20) * time.Second,
+						Hedge:     1,
+						StartedAt: time.Now(),
+					},
+				},
+				Cached:      true,
+				Dependency:  "google",
+				Duration:    time.Duration(120) * time.Second,
+				Error:       nil,
+				Fallback:    "cache",
+				Fault:       "outage",
+				Hedge:       1,
+				Name:        "google.Search",
+				StartedAt:   time.Now(),
+				WouldReject: []string{"breaker_open"},
 			},
 		},
 		Environment: "dev",
@@ -103,6 +124,9 @@ func (s *ClientTestSuite) assertRecordCallsRequest(req errcatapi.RecordCallsRequ
 }
 
 func (s *ClientTestSuite) assertCall(call errcatapi.Call, protoCall *pb.Call) {
+	s.Equal(call.Cached, protoCall.GetCached())
+	s.Equal(call.Categories, protoCall.GetCategories())
+	s.Equal(call.Coalesced, uint(protoCall.GetCoalesced()))
 	s.Equal(call.Dependency, protoCall.GetDependency())
 	s.Equal(call.Duration, protoCall.GetDuration().AsDuration())
 	if call.Error == nil {
@@ -110,6 +134,28 @@ func (s *ClientTestSuite) assertCall(call errcatapi.Call, protoCall *pb.Call) {
 	} else {
 		s.Equal(call.Error.Error(), protoCall.GetError())
 	}
+	s.Equal(call.AttemptTimeout, protoCall.GetAttemptTimeout().AsDuration())
+	s.Equal(call.Fallback, protoCall.GetFallback())
+	s.Equal(call.Fault, protoCall.GetFault())
+	s.Equal(call.Hedge, uint(protoCall.GetHedge()))
+	s.Equal(call.Labels, protoCall.GetLabels())
 	s.Equal(call.Name, protoCall.GetName())
 	s.Equal(call.StartedAt.UTC(), protoCall.GetStartedAt().AsTime().UTC())
+	s.Equal(call.Timeout, protoCall.GetTimeout().AsDuration())
+	s.Equal(call.WouldReject, protoCall.GetWouldReject())
+	s.Require().Len(protoCall.GetAttempts(), len(call.Attempts))
+	for i, protoAttempt := range protoCall.GetAttempts() {
+		s.assertAttempt(call.Attempts[i], protoAttempt)
+	}
+}
+
+func (s *ClientTestSuite) assertAttempt(attempt errcatapi.Attempt, protoAttempt *pb.Attempt) {
+	s.Equal(attempt.Duration, protoAttempt.GetDuration().AsDuration())
+	if attempt.Error == nil {
+		s.Equal("", protoAttempt.GetError())
+	} else {
+		s.Equal(attempt.Error.Error(), protoAttempt.GetError())
+	}
+	s.Equal(attempt.Hedge, uint(protoAttempt.GetHedge()))
+	s.Equal(attempt.StartedAt.UTC(), protoAttempt.GetStartedAt().AsTime().UTC())
 }
@@ -1,10 +1,14 @@
 package breaker
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/agschwender/errcat-go/internal/errs"
+	"github.com/agschwender/errcat-go/shadow"
 )
 
 // ErrBreakerOpen indicates that the breaker is in the open state and
@@ -12,6 +16,38 @@ import (
 // in the half open state and at capacity.
 var ErrBreakerOpen = errors.New("circuit breaker is open")
 
+// BreakerOpenError is the error returned when the breaker rejects a
+// call. It satisfies errors.Is for ErrBreakerOpen.
+type BreakerOpenError struct {
+	// Breaker is the name of the breaker, if it has one.
+	Breaker string
+
+	// Dependency and Name identify the caller that made the call, when
+	// the breaker is run by one.
+	Dependency string
+	Name       string
+
+	// HalfOpenIn is the time remaining until the breaker transitions to
+	// the half-open state. This is zero when the breaker is already
+	// half-open and at capacity.
+	HalfOpenIn time.Duration
+}
+
+func (e *BreakerOpenError) Error() string {
+	msg := ErrBreakerOpen.Error()
+	if e.Breaker != "" {
+		msg = fmt.Sprintf("circuit breaker %q is open", e.Breaker)
+	}
+	if e.HalfOpenIn > 0 {
+		msg = fmt.Sprintf("%s, half-open in %s", msg, e.HalfOpenIn)
+	}
+	return errs.Describe(e.Dependency, e.Name, msg)
+}
+
+func (e *BreakerOpenError) Unwrap() error {
+	return ErrBreakerOpen
+}
+
 type Status uint8
 
 func (s Status) String() string {
@@ -39,8 +75,10 @@ const (
 	// this state.
 	Open Status = 2
 
+	defaultFailureRate = 0.5
 	defaultMaxFailures = uint(5)
 	defaultMaxRequests = uint(1)
+	defaultMinCalls    = uint(10)
 	defaultTimeout     = time.Duration(60) * time.Second
 )
 
@@ -60,6 +98,14 @@ type State struct {
 	// the circuit breaker is in the half open state.
 	Successes uint
 
+	// The number of calls and failures within the rolling window and
+	// the resulting failure rate. These are only tracked when the
+	// circuit breaker is configured with a window and is in the closed
+	// state.
+	WindowCalls    uint
+	WindowFailures uint
+	FailureRate    float64
+
 	now func() time.Time
 }
 
@@ -84,15 +130,20 @@ func (s *State) success() {
 // Breaker provides the logic for conditionally calling a function based
 // on its passed performance.
 type Breaker struct {
+	failureRate float64
 	isFailure   func(err error) bool
 	maxFailures uint
 	maxRequests uint
+	minCalls    uint
+	name        string
 	now         func() time.Time
+	shadowMode  bool
 	timeout     time.Duration
 
 	lock     sync.RWMutex
 	requests uint
 	state    State
+	window   window
 }
 
 type option func(*Breaker)
@@ -100,9 +151,11 @@ type option func(*Breaker)
 // New creates a new Breaker with the supplied options.
 func New(opts ...option) *Breaker {
 	b := &Breaker{
+		failureRate: defaultFailureRate,
 		isFailure:   defaultIsFailure,
 		maxFailures: defaultMaxFailures,
 		maxRequests: defaultMaxRequests,
+		minCalls:    defaultMinCalls,
 		now:         time.Now,
 		timeout:     defaultTimeout,
 	}
@@ -116,6 +169,66 @@ func New(opts ...option) *Breaker {
 	return b
 }
 
+// Reconfigure applies the options to the breaker while preserving its
+// state, e.g. a breaker that is open remains open. The calls recorded
+// in its window are only preserved when the window is unchanged;
+// otherwise, the new window starts out empty. This allows the settings
+// of a breaker in use to be changed without losing track of the
+// dependency's health.
+func (b *Breaker) Reconfigure(opts ...option) {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	prev := b.window
+	for _, opt := range opts {
+		opt(b)
+	}
+	if sameWindow(prev, b.window) {
+		b.window = prev
+	}
+
+	b.state.now = b.now
+}
+
+// windowMinCalls returns the minimum calls limited to the size of a
+// count window, since the window can never hold more calls than its
+// size and so would otherwise never open. The configured minimum is
+// kept, so that it applies again if the window grows.
+func (b *Breaker) windowMinCalls() uint {
+	if w, ok := b.window.(*countWindow); ok && b.minCalls > uint(len(w.outcomes)) {
+		return uint(len(w.outcomes))
+	}
+	return b.minCalls
+}
+
+// WithCountWindow indicates the breaker should go into the open state
+// based on the failure rate of the last size calls, rather than after
+// a number of consecutive failures. A size of zero restores the
+// consecutive failure behavior.
+func WithCountWindow(size uint) option {
+	return func(b *Breaker) {
+		b.window = nil
+		if size > 0 {
+			b.window = newCountWindow(size)
+		}
+	}
+}
+
+// WithFailureRate sets the failure rate, between 0 and 1, at which a
+// breaker configured with a window will go into the open state.
+func WithFailureRate(failureRate float64) option {
+	return func(b *Breaker) {
+		if failureRate <= 0 || failureRate > 1 {
+			failureRate = defaultFailureRate
+		}
+		b.failureRate = failureRate
+	}
+}
+
 // WithIsFailure defines the logic for determining if the error should be
 // counted toward the breaker failures.
 func WithIsFailure(isFailure func(err error) bool) option {
@@ -149,6 +262,28 @@ func WithMaxFailures(maxFailures uint) option {
 	}
 }
 
+// WithMinCalls sets the minimum number of calls that must be made
+// within the window before a breaker configured with a window can go
+// into the open state. This prevents a small number of failures from
+// tripping the breaker when there is little traffic.
+func WithMinCalls(minCalls uint) option {
+	return func(b *Breaker) {
+		if minCalls == 0 {
+			minCalls = defaultMinCalls
+		}
+		b.minCalls = minCalls
+	}
+}
+
+// WithName sets the name of the breaker, which is included in the
+// errors it returns. This is useful when a breaker is shared by
+// multiple callers.
+func WithName(name string) option {
+	return func(b *Breaker) {
+		b.name = name
+	}
+}
+
 // WithNow sets the function for getting the current time. This is only
 // useful for testing.
 func WithNow(now func() time.Time) option {
@@ -160,6 +295,32 @@ func WithNow(now func() time.Time) option {
 	}
 }
 
+// WithShadowMode indicates whether the breaker should run in shadow
+// mode, in which its state changes as usual but calls are never
+// rejected. Calls the breaker would have rejected are reported using
+// the shadow package instead and, since the breaker would not have
+// seen them, are not counted towards its state. This allows the
+// settings of a breaker to be tuned against real traffic before it is
+// enforced.
+func WithShadowMode(enabled bool) option {
+	return func(b *Breaker) {
+		b.shadowMode = enabled
+	}
+}
+
+// WithTimeWindow indicates the breaker should go into the open state
+// based on the failure rate of the calls made within the last duration,
+// rather than after a number of consecutive failures. A duration of
+// zero restores the consecutive failure behavior.
+func WithTimeWindow(d time.Duration) option {
+	return func(b *Breaker) {
+		b.window = nil
+		if d > 0 {
+			b.window = newTimeWindow(d)
+		}
+	}
+}
+
 // WithTimeout sets the duration over which the breaker will remain in
 // the open state.
 func WithTimeout(timeout time.Duration) option {
@@ -174,26 +335,52 @@ func WithTimeout(timeout time.Duration) option {
 // Run executes the callback if the circuit breaker is not in the open
 // state. It will track successes and failures in order to determine the
 // state.
-func (b *Breaker) Run(cb func() error) (err error) {
-	if b == nil {
+func (b *Breaker) Run(cb func() error) error {
+	return b.RunContext(context.Background(), func(context.Context) error {
 		return cb()
+	})
+}
+
+// RunContext executes the callback if the circuit breaker is not in the
+// open state. Errors that occur after the context has been cancelled
+// are the result of the caller abandoning the call rather than the
+// dependency failing, so they are not counted against the breaker.
+func (b *Breaker) RunContext(ctx context.Context, cb func(ctx context.Context) error) (err error) {
+	if b == nil {
+		return cb(ctx)
 	}
 
 	state := b.State()
 	status := state.Status()
 	if status == Open {
-		return ErrBreakerOpen
+		return b.reject(ctx, &BreakerOpenError{Breaker: b.Name(), HalfOpenIn: state.expiresAt.Sub(state.now())}, cb)
 	}
 	if status == HalfOpen && !b.canMakeHalfOpenRequest() {
-		return ErrBreakerOpen
+		return b.reject(ctx, &BreakerOpenError{Breaker: b.Name()}, cb)
 	}
 
-	err = b.safeRun(cb)
+	err = b.safeRun(ctx, cb)
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		b.releaseHalfOpenRequest(status)
+		return err
+	}
 	b.handleError(state, err)
 
 	return err
 }
 
+// Name returns the name of the breaker.
+func (b *Breaker) Name() string {
+	if b == nil {
+		return ""
+	}
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.name
+}
+
 // State returns the current breaker state.
 func (b *Breaker) State() State {
 	if b == nil {
@@ -203,7 +390,12 @@ func (b *Breaker) State() State {
 	b.lock.RLock()
 	defer b.lock.RUnlock()
 
-	return b.state
+	state := b.state
+	if b.window != nil && state.status == Closed {
+		state.WindowCalls, state.WindowFailures = b.window.totals(b.now())
+		state.FailureRate = failureRate(state.WindowCalls, state.WindowFailures)
+	}
+	return state
 }
 
 func (b *Breaker) canMakeHalfOpenRequest() bool {
@@ -219,12 +411,18 @@ func (b *Breaker) canMakeHalfOpenRequest() bool {
 }
 
 func (b *Breaker) handleError(state State, err error) {
+	// The settings are read under the lock since the breaker may be
+	// reconfigured while in use.
+	b.lock.RLock()
 	isFailure := err != nil && b.isFailure(err)
+	windowed := b.window != nil
+	b.lock.RUnlock()
 
 	// Since we only track failures in the closed state, we can exit
 	// early without accessing the lock as long as we do not need reset
-	// the failures.
-	if state.status == Closed && !isFailure && state.Failures == 0 {
+	// the failures. Windows track successes too, so they cannot exit
+	// early.
+	if state.status == Closed && !isFailure && state.Failures == 0 && !windowed {
 		return
 	}
 
@@ -233,8 +431,19 @@ func (b *Breaker) handleError(state State, err error) {
 
 	switch b.state.Status() {
 	case Closed:
-		// When in the closed state, we track failures only and check if
-		// the circuit breaker should transition into the open state.
+		// When configured with a window, the outcome of every call is
+		// tracked and the failure rate determines whether the circuit
+		// breaker should transition into the open state.
+		if b.window != nil {
+			b.window.record(b.now(), isFailure)
+			if b.shouldOpen() {
+				b.setState(Open)
+			}
+			return
+		}
+
+		// Otherwise, we track failures only and check if the circuit
+		// breaker should transition into the open state.
 		if isFailure {
 			b.state.failure()
 			if b.shouldOpen() {
@@ -260,14 +469,42 @@ func (b *Breaker) handleError(state State, err error) {
 
 }
 
-func (b *Breaker) safeRun(cb func() error) (err error) {
+// reject returns the error of a rejected call unless the breaker is in
+// shadow mode, in which case the rejection is reported and the callback
+// is run without affecting the state.
+func (b *Breaker) reject(ctx context.Context, err error, cb func(ctx context.Context) error) error {
+	b.lock.RLock()
+	shadowMode := b.shadowMode
+	b.lock.RUnlock()
+
+	if !shadowMode {
+		return err
+	}
+	shadow.Report(ctx, err)
+	return b.safeRun(ctx, cb)
+}
+
+func (b *Breaker) releaseHalfOpenRequest(status Status) {
+	if status != HalfOpen {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state.Status() == HalfOpen && b.requests > 0 {
+		b.requests--
+	}
+}
+
+func (b *Breaker) safeRun(ctx context.Context, cb func(ctx context.Context) error) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("%v", r)
+			err = errs.NewPanicError(r)
 		}
 	}()
 
-	err = cb()
+	err = cb(ctx)
 	return
 }
 
@@ -276,11 +513,25 @@ func (b *Breaker) setState(status Status) {
 	// state and counts variables.
 	b.requests = 0
 	b.state = State{status: status, now: b.now}
+	if b.window != nil {
+		b.window.reset()
+	}
 	if status == Open {
 		b.state.expiresAt = b.now().Add(b.timeout)
 	}
 }
 
 func (b *Breaker) shouldOpen() bool {
+	if b.window != nil {
+		calls, failures := b.window.totals(b.now())
+		return calls >= b.windowMinCalls() && failureRate(calls, failures) >= b.failureRate
+	}
 	return b.state.Failures >= b.maxFailures
 }
+
+func failureRate(calls, failures uint) float64 {
+	if calls == 0 {
+		return 0
+	}
+	return float64(failures) / float64(calls)
+}
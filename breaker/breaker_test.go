@@ -1,6 +1,7 @@
 package breaker_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/shadow"
 )
 
 func TestAsNil(t *testing.T) {
@@ -56,7 +58,12 @@ func TestWithDefaults(t *testing.T) {
 	// Confirm that it automatically returns an open error
 	err = b.Run(func() error { return fmt.Errorf("oops") })
 	require.Error(t, err)
-	assert.Equal(t, breaker.ErrBreakerOpen, err)
+	assert.ErrorIs(t, err, breaker.ErrBreakerOpen)
+
+	var openErr *breaker.BreakerOpenError
+	require.ErrorAs(t, err, &openErr)
+	assert.Equal(t, time.Duration(60)*time.Second, openErr.HalfOpenIn)
+	assert.Equal(t, "circuit breaker is open, half-open in 1m0s", err.Error())
 
 	// Move the time to the timeout duration
 	now = now.Add(time.Duration(60) * time.Second)
@@ -89,7 +96,7 @@ func TestWithDefaults(t *testing.T) {
 		<-second
 		err := b.Run(func() error { return nil })
 		require.Error(t, err)
-		assert.Equal(t, breaker.ErrBreakerOpen, err)
+		assert.ErrorIs(t, err, breaker.ErrBreakerOpen)
 		first <- true
 	}()
 
@@ -99,6 +106,47 @@ func TestWithDefaults(t *testing.T) {
 	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
 }
 
+func TestRunContextCancelled(t *testing.T) {
+	now := time.Now()
+
+	b := breaker.New(
+		breaker.WithNow(func() time.Time { return now }),
+		breaker.WithMaxFailures(uint(1)),
+	)
+
+	// A cancelled call is not counted as a failure
+	ctx, cancel := context.WithCancel(context.Background())
+	err := b.RunContext(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+	require.Error(t, err)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
+	assert.Equal(t, 0, int(b.State().Failures))
+
+	// A failure with a live context is counted
+	err = b.RunContext(context.Background(), func(ctx context.Context) error {
+		return fmt.Errorf("oops")
+	})
+	require.Error(t, err)
+	assert.Equal(t, breaker.Open.String(), b.State().Status().String())
+
+	// A cancelled half open request does not consume the half open
+	// capacity.
+	now = now.Add(time.Duration(60) * time.Second)
+	ctx, cancel = context.WithCancel(context.Background())
+	b.RunContext(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+	assert.Equal(t, breaker.HalfOpen.String(), b.State().Status().String())
+
+	err = b.RunContext(context.Background(), func(ctx context.Context) error { return nil })
+	require.NoError(t, err)
+	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
+}
+
 func TestWithNowNil(t *testing.T) {
 	b := breaker.New(breaker.WithNow(nil))
 	for i := 0; i < 10; i++ {
@@ -143,7 +191,7 @@ func TestWithOverrides(t *testing.T) {
 	// Confirm that it automatically returns an open error
 	err = b.Run(func() error { return fmt.Errorf("oops") })
 	require.Error(t, err)
-	assert.Equal(t, breaker.ErrBreakerOpen, err)
+	assert.ErrorIs(t, err, breaker.ErrBreakerOpen)
 
 	// Move the time to the timeout duration
 	now = now.Add(time.Duration(10) * time.Second)
@@ -174,6 +222,19 @@ func TestWithPanic(t *testing.T) {
 	assert.Equal(t, "oops", err.Error())
 }
 
+func TestWithName(t *testing.T) {
+	b := breaker.New(breaker.WithName("users"), breaker.WithMaxFailures(1))
+	assert.Equal(t, "users", b.Name())
+
+	b.Run(func() error { return fmt.Errorf("oops") })
+	err := b.Run(func() error { return nil })
+
+	var openErr *breaker.BreakerOpenError
+	require.ErrorAs(t, err, &openErr)
+	assert.Equal(t, "users", openErr.Breaker)
+	assert.Contains(t, err.Error(), `circuit breaker "users" is open`)
+}
+
 func TestWithZeroValues(t *testing.T) {
 	now := time.Now()
 
@@ -206,3 +267,217 @@ func TestWithZeroValues(t *testing.T) {
 	b.Run(func() error { return nil })
 	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
 }
+
+func TestWithCountWindow(t *testing.T) {
+	now := time.Now()
+
+	b := breaker.New(
+		breaker.WithNow(func() time.Time { return now }),
+		breaker.WithCountWindow(uint(10)),
+		breaker.WithFailureRate(0.7),
+		breaker.WithMinCalls(uint(5)),
+	)
+
+	// The breaker cannot open until the minimum calls are made
+	b.Run(func() error { return fmt.Errorf("oops") })
+	b.Run(func() error { return fmt.Errorf("oops") })
+	b.Run(func() error { return fmt.Errorf("oops") })
+	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
+	assert.Equal(t, 3, int(b.State().WindowCalls))
+	assert.Equal(t, 3, int(b.State().WindowFailures))
+	assert.Equal(t, 1.0, b.State().FailureRate)
+
+	b.Run(func() error { return nil })
+	b.Run(func() error { return nil })
+	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
+	assert.Equal(t, 0.6, b.State().FailureRate)
+
+	// Filling the window evicts the oldest outcomes
+	for i := 0; i < 5; i++ {
+		b.Run(func() error { return nil })
+	}
+	b.Run(func() error { return fmt.Errorf("oops") })
+	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
+	assert.Equal(t, 10, int(b.State().WindowCalls))
+	assert.Equal(t, 3, int(b.State().WindowFailures))
+
+	for i := 0; i < 5; i++ {
+		b.Run(func() error { return fmt.Errorf("oops") })
+	}
+	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
+	assert.Equal(t, 0.6, b.State().FailureRate)
+
+	b.Run(func() error { return fmt.Errorf("oops") })
+	assert.Equal(t, breaker.Open.String(), b.State().Status().String())
+	assert.Equal(t, 0, int(b.State().WindowCalls))
+
+	// Returning to the closed state starts with an empty window
+	now = now.Add(time.Duration(60) * time.Second)
+	b.Run(func() error { return nil })
+	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
+	assert.Equal(t, 0, int(b.State().WindowCalls))
+}
+
+func TestWithCountWindowSmallerThanMinCalls(t *testing.T) {
+	b := breaker.New(breaker.WithCountWindow(uint(4)))
+	for i := 0; i < 4; i++ {
+		b.Run(func() error { return fmt.Errorf("oops") })
+	}
+	assert.Equal(t, breaker.Open.String(), b.State().Status().String())
+}
+
+func TestWithTimeWindow(t *testing.T) {
+	now := time.Now()
+
+	b := breaker.New(
+		breaker.WithNow(func() time.Time { return now }),
+		breaker.WithTimeWindow(time.Duration(10)*time.Second),
+		breaker.WithMinCalls(uint(4)),
+	)
+
+	// Failures older than the window expire
+	b.Run(func() error { return fmt.Errorf("oops") })
+	b.Run(func() error { return fmt.Errorf("oops") })
+	b.Run(func() error { return fmt.Errorf("oops") })
+	assert.Equal(t, 3, int(b.State().WindowFailures))
+
+	now = now.Add(time.Duration(11) * time.Second)
+	assert.Equal(t, 0, int(b.State().WindowCalls))
+
+	b.Run(func() error { return fmt.Errorf("oops") })
+	b.Run(func() error { return nil })
+	b.Run(func() error { return nil })
+	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
+	assert.Equal(t, 3, int(b.State().WindowCalls))
+
+	// Calls spread over the window are all counted
+	now = now.Add(time.Duration(5) * time.Second)
+	b.Run(func() error { return fmt.Errorf("oops") })
+	assert.Equal(t, breaker.Open.String(), b.State().Status().String())
+}
+
+func TestWithWindowZeroValues(t *testing.T) {
+	b := breaker.New(
+		breaker.WithCountWindow(uint(10)),
+		breaker.WithTimeWindow(0),
+		breaker.WithFailureRate(0),
+		breaker.WithMinCalls(0),
+	)
+
+	// Without a window, the consecutive failures are used
+	for i := 0; i < 5; i++ {
+		b.Run(func() error { return fmt.Errorf("oops") })
+	}
+	assert.Equal(t, breaker.Open.String(), b.State().Status().String())
+}
+
+func TestReconfigure(t *testing.T) {
+	now := time.Now()
+
+	b := breaker.New(
+		breaker.WithNow(func() time.Time { return now }),
+		breaker.WithMaxFailures(2),
+	)
+
+	// Confirm the state is preserved and the new settings apply
+	b.Run(func() error { return fmt.Errorf("oops") })
+	b.Reconfigure(breaker.WithMaxFailures(3), breaker.WithName("users"))
+	assert.Equal(t, 1, int(b.State().Failures))
+	assert.Equal(t, "users", b.Name())
+
+	b.Run(func() error { return fmt.Errorf("oops") })
+	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
+	b.Run(func() error { return fmt.Errorf("oops") })
+	assert.Equal(t, breaker.Open.String(), b.State().Status().String())
+
+	b.Reconfigure(breaker.WithTimeout(time.Duration(10) * time.Second))
+	assert.Equal(t, breaker.Open.String(), b.State().Status().String())
+
+	// Confirm the window is preserved when it is unchanged
+	b = breaker.New(
+		breaker.WithNow(func() time.Time { return now }),
+		breaker.WithCountWindow(uint(10)),
+	)
+	b.Run(func() error { return fmt.Errorf("oops") })
+	b.Run(func() error { return nil })
+
+	b.Reconfigure(breaker.WithCountWindow(uint(10)), breaker.WithFailureRate(0.9))
+	assert.Equal(t, 2, int(b.State().WindowCalls))
+	assert.Equal(t, 1, int(b.State().WindowFailures))
+
+	// Confirm the window starts out empty when it changes
+	b.Reconfigure(breaker.WithCountWindow(uint(20)))
+	assert.Equal(t, 0, int(b.State().WindowCalls))
+
+	b.Run(func() error { return nil })
+	b.Reconfigure(breaker.WithTimeWindow(time.Minute))
+	assert.Equal(t, 0, int(b.State().WindowCalls))
+
+	// Confirm the minimum calls are limited to the new window size
+	b.Reconfigure(breaker.WithCountWindow(uint(2)), breaker.WithMinCalls(uint(10)))
+	b.Run(func() error { return fmt.Errorf("oops") })
+	b.Run(func() error { return fmt.Errorf("oops") })
+	assert.Equal(t, breaker.Open.String(), b.State().Status().String())
+
+	// Confirm the configured minimum calls apply again once the window
+	// grows
+	b = breaker.New(
+		breaker.WithNow(func() time.Time { return now }),
+		breaker.WithCountWindow(uint(20)),
+		breaker.WithMinCalls(uint(10)),
+	)
+	b.Reconfigure(breaker.WithCountWindow(uint(2)))
+	b.Reconfigure(breaker.WithCountWindow(uint(20)))
+	for i := 0; i < 9; i++ {
+		b.Run(func() error { return fmt.Errorf("oops") })
+	}
+	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
+	b.Run(func() error { return fmt.Errorf("oops") })
+	assert.Equal(t, breaker.Open.String(), b.State().Status().String())
+}
+
+func TestWithShadowMode(t *testing.T) {
+	now := time.Now()
+
+	b := breaker.New(
+		breaker.WithMaxFailures(uint(2)),
+		breaker.WithNow(func() time.Time { return now }),
+		breaker.WithShadowMode(true),
+		breaker.WithTimeout(time.Duration(10)*time.Second),
+	)
+
+	var reported []error
+	ctx := shadow.NewContext(context.Background(), func(err error) {
+		reported = append(reported, err)
+	})
+	run := func(err error) (bool, error) {
+		called := false
+		err = b.RunContext(ctx, func(context.Context) error {
+			called = true
+			return err
+		})
+		return called, err
+	}
+
+	// Confirm the state changes as usual
+	run(fmt.Errorf("oops"))
+	run(fmt.Errorf("oops"))
+	assert.Equal(t, breaker.Open.String(), b.State().Status().String())
+	assert.Empty(t, reported)
+
+	// Confirm the call is made, rather than rejected, and the rejection
+	// is reported
+	called, err := run(nil)
+	require.NoError(t, err)
+	assert.True(t, called)
+	require.Len(t, reported, 1)
+	assert.ErrorIs(t, reported[0], breaker.ErrBreakerOpen)
+
+	// Confirm calls that would have been rejected do not affect the state
+	assert.Equal(t, breaker.Open.String(), b.State().Status().String())
+	now = now.Add(time.Duration(10) * time.Second)
+	assert.Equal(t, breaker.HalfOpen.String(), b.State().Status().String())
+	run(nil)
+	assert.Equal(t, breaker.Closed.String(), b.State().Status().String())
+	assert.Len(t, reported, 1)
+}
@@ -0,0 +1,25 @@
+package breaker_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go/breaker"
+)
+
+func TestRegistry(t *testing.T) {
+	r := breaker.NewRegistry()
+
+	b := breaker.New(breaker.WithName("users"))
+	require.NoError(t, r.Register("users", b))
+	assert.EqualError(t, r.Register("users", breaker.New()), `breaker already registered with the name of "users"`)
+
+	found, ok := r.Get("users")
+	assert.True(t, ok)
+	assert.Same(t, b, found)
+
+	_, ok = r.Get("orders")
+	assert.False(t, ok)
+}
@@ -0,0 +1,129 @@
+package breaker
+
+import (
+	"time"
+)
+
+const timeWindowBuckets = 10
+
+// window tracks the outcome of recent calls in order to calculate the
+// failure rate. Implementations are not safe for concurrent use and
+// rely on the breaker's lock.
+type window interface {
+	record(now time.Time, failure bool)
+	reset()
+	totals(now time.Time) (calls, failures uint)
+}
+
+// countWindow tracks the outcome of the last size calls.
+type countWindow struct {
+	outcomes []bool
+	next     int
+	calls    uint
+	failures uint
+}
+
+func newCountWindow(size uint) *countWindow {
+	return &countWindow{outcomes: make([]bool, size)}
+}
+
+func (w *countWindow) record(_ time.Time, failure bool) {
+	if w.calls == uint(len(w.outcomes)) {
+		// The window is full, so the oldest outcome is evicted.
+		if w.outcomes[w.next] {
+			w.failures--
+		}
+	} else {
+		w.calls++
+	}
+
+	w.outcomes[w.next] = failure
+	if failure {
+		w.failures++
+	}
+	w.next = (w.next + 1) % len(w.outcomes)
+}
+
+func (w *countWindow) reset() {
+	w.next = 0
+	w.calls = 0
+	w.failures = 0
+}
+
+func (w *countWindow) totals(time.Time) (uint, uint) {
+	return w.calls, w.failures
+}
+
+type bucket struct {
+	epoch    int64
+	calls    uint
+	failures uint
+}
+
+// timeWindow tracks the outcome of calls made within the last duration.
+// The duration is divided into buckets, so calls expire from the
+// window a bucket at a time.
+type timeWindow struct {
+	buckets []bucket
+	width   int64
+}
+
+func newTimeWindow(d time.Duration) *timeWindow {
+	width := int64(d) / timeWindowBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &timeWindow{
+		buckets: make([]bucket, timeWindowBuckets),
+		width:   width,
+	}
+}
+
+func (w *timeWindow) record(now time.Time, failure bool) {
+	epoch := w.epoch(now)
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if b.epoch != epoch {
+		*b = bucket{epoch: epoch}
+	}
+
+	b.calls++
+	if failure {
+		b.failures++
+	}
+}
+
+func (w *timeWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
+
+func (w *timeWindow) totals(now time.Time) (calls, failures uint) {
+	epoch := w.epoch(now)
+	for _, b := range w.buckets {
+		if b.epoch <= epoch && epoch-b.epoch < int64(len(w.buckets)) {
+			calls += b.calls
+			failures += b.failures
+		}
+	}
+	return calls, failures
+}
+
+func (w *timeWindow) epoch(now time.Time) int64 {
+	return now.UnixNano() / w.width
+}
+
+// sameWindow indicates whether the windows are of the same type and
+// size, such that the calls recorded by one are valid for the other.
+func sameWindow(a, b window) bool {
+	switch a := a.(type) {
+	case *countWindow:
+		b, ok := b.(*countWindow)
+		return ok && len(a.outcomes) == len(b.outcomes)
+	case *timeWindow:
+		b, ok := b.(*timeWindow)
+		return ok && a.width == b.width
+	default:
+		return false
+	}
+}
@@ -0,0 +1,225 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/agschwender/errcat-go/shadow"
+)
+
+// ErrBulkheadFull indicates that the maximum number of concurrent calls
+// has been reached and the call could not wait for one to complete.
+var ErrBulkheadFull = errors.New("bulkhead is full")
+
+const defaultMaxConcurrent = uint(10)
+
+// Bulkhead limits the number of calls that may run concurrently, which
+// prevents a slow dependency from consuming all of the available
+// goroutines. Calls beyond the limit are rejected unless the bulkhead
+// is configured with a queue, in which case they may wait for a
+// running call to complete.
+type Bulkhead struct {
+	maxConcurrent uint
+	maxQueue      uint
+	maxWait       time.Duration
+	shadowMode    bool
+
+	lock     sync.Mutex
+	overflow uint
+	queued   uint
+	slots    chan struct{}
+}
+
+type option func(*Bulkhead)
+
+// New creates a new Bulkhead with the supplied options.
+func New(opts ...option) *Bulkhead {
+	b := &Bulkhead{
+		maxConcurrent: defaultMaxConcurrent,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	b.slots = make(chan struct{}, b.maxConcurrent)
+
+	return b
+}
+
+// WithMaxConcurrent sets the maximum number of calls that may run at
+// the same time.
+func WithMaxConcurrent(maxConcurrent uint) option {
+	return func(b *Bulkhead) {
+		if maxConcurrent == 0 {
+			maxConcurrent = defaultMaxConcurrent
+		}
+		b.maxConcurrent = maxConcurrent
+	}
+}
+
+// WithMaxQueue sets the maximum number of calls that may wait for a
+// running call to complete. By default, calls are rejected as soon as
+// the bulkhead is full.
+func WithMaxQueue(maxQueue uint) option {
+	return func(b *Bulkhead) {
+		b.maxQueue = maxQueue
+	}
+}
+
+// WithMaxWait sets the maximum duration a queued call will wait for a
+// running call to complete. By default, queued calls wait until their
+// context is done.
+func WithMaxWait(maxWait time.Duration) option {
+	return func(b *Bulkhead) {
+		b.maxWait = maxWait
+	}
+}
+
+// WithShadowMode indicates whether the bulkhead should run in shadow
+// mode, in which calls are never rejected or queued. Calls made while
+// the bulkhead is full run without a slot, and those the bulkhead would
+// have rejected are reported using the shadow package instead.
+func WithShadowMode(enabled bool) option {
+	return func(b *Bulkhead) {
+		b.shadowMode = enabled
+	}
+}
+
+// Run executes the callback if the bulkhead is not full.
+func (b *Bulkhead) Run(cb func() error) error {
+	return b.RunContext(context.Background(), func(context.Context) error {
+		return cb()
+	})
+}
+
+// RunContext executes the callback if the bulkhead is not full,
+// otherwise it waits in the queue, if there is room, until a running
+// call completes, the maximum wait is exceeded or the context is done.
+func (b *Bulkhead) RunContext(ctx context.Context, cb func(ctx context.Context) error) error {
+	if b == nil {
+		return cb(ctx)
+	}
+
+	if b.shadowMode {
+		return b.runShadow(ctx, cb)
+	}
+
+	if err := b.acquire(ctx); err != nil {
+		return err
+	}
+	defer b.release()
+
+	return cb(ctx)
+}
+
+// runShadow executes the callback with a slot if one is free, otherwise
+// without one. The calls running without a slot stand in for those that
+// would have been queued, so the rejection is reported once there are
+// more of them than the queue would hold.
+func (b *Bulkhead) runShadow(ctx context.Context, cb func(ctx context.Context) error) error {
+	select {
+	case b.slots <- struct{}{}:
+		defer b.release()
+		return cb(ctx)
+	default:
+	}
+
+	if !b.startOverflow() {
+		shadow.Report(ctx, ErrBulkheadFull)
+		return cb(ctx)
+	}
+	defer b.endOverflow()
+	return cb(ctx)
+}
+
+// InFlight returns the number of calls currently running.
+func (b *Bulkhead) InFlight() uint {
+	if b == nil {
+		return 0
+	}
+	return uint(len(b.slots))
+}
+
+// Queued returns the number of calls currently waiting to run.
+func (b *Bulkhead) Queued() uint {
+	if b == nil {
+		return 0
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.queued
+}
+
+func (b *Bulkhead) acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if !b.enqueue() {
+		return ErrBulkheadFull
+	}
+	defer b.dequeue()
+
+	var expired <-chan time.Time
+	if b.maxWait > 0 {
+		t := time.NewTimer(b.maxWait)
+		defer t.Stop()
+		expired = t.C
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-expired:
+		return ErrBulkheadFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Bulkhead) enqueue() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.queued >= b.maxQueue {
+		return false
+	}
+	b.queued++
+	return true
+}
+
+func (b *Bulkhead) dequeue() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.queued--
+}
+
+func (b *Bulkhead) startOverflow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.overflow >= b.maxQueue {
+		return false
+	}
+	b.overflow++
+	return true
+}
+
+func (b *Bulkhead) endOverflow() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.overflow--
+}
+
+func (b *Bulkhead) release() {
+	<-b.slots
+}
@@ -0,0 +1,216 @@
+package bulkhead_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go/bulkhead"
+	"github.com/agschwender/errcat-go/shadow"
+)
+
+// fill occupies n slots of the bulkhead until the returned function is
+// called.
+func fill(t *testing.T, b *bulkhead.Bulkhead, n int) func() {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	started := make(chan bool)
+	release := make(chan bool)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Run(func() error {
+				started <- true
+				<-release
+				return nil
+			})
+		}()
+		<-started
+	}
+
+	return func() {
+		close(release)
+		wg.Wait()
+	}
+}
+
+func TestAsNil(t *testing.T) {
+	var b *bulkhead.Bulkhead
+	assert.Equal(t, uint(0), b.InFlight())
+	assert.Equal(t, uint(0), b.Queued())
+
+	counts := 0
+	err := b.Run(func() error {
+		counts++
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, counts)
+}
+
+func TestWithDefaults(t *testing.T) {
+	b := bulkhead.New()
+
+	err := b.Run(func() error { return fmt.Errorf("oops") })
+	require.Error(t, err)
+	assert.Equal(t, "oops", err.Error())
+
+	release := fill(t, b, 10)
+	assert.Equal(t, uint(10), b.InFlight())
+
+	// Without a queue, calls are rejected immediately
+	err = b.Run(func() error { return nil })
+	assert.Equal(t, bulkhead.ErrBulkheadFull, err)
+
+	release()
+	assert.Equal(t, uint(0), b.InFlight())
+
+	err = b.Run(func() error { return nil })
+	require.NoError(t, err)
+}
+
+func TestWithMaxQueue(t *testing.T) {
+	b := bulkhead.New(
+		bulkhead.WithMaxConcurrent(uint(1)),
+		bulkhead.WithMaxQueue(uint(1)),
+	)
+
+	release := fill(t, b, 1)
+
+	// The first call waits in the queue until a slot frees up
+	done := make(chan error)
+	go func() {
+		done <- b.Run(func() error { return nil })
+	}()
+	for b.Queued() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queue is full, so the next call is rejected
+	err := b.Run(func() error { return nil })
+	assert.Equal(t, bulkhead.ErrBulkheadFull, err)
+
+	release()
+	require.NoError(t, <-done)
+	assert.Equal(t, uint(0), b.Queued())
+}
+
+func TestWithMaxWait(t *testing.T) {
+	b := bulkhead.New(
+		bulkhead.WithMaxConcurrent(uint(1)),
+		bulkhead.WithMaxQueue(uint(1)),
+		bulkhead.WithMaxWait(time.Duration(10)*time.Millisecond),
+	)
+
+	release := fill(t, b, 1)
+	defer release()
+
+	err := b.Run(func() error { return nil })
+	assert.Equal(t, bulkhead.ErrBulkheadFull, err)
+	assert.Equal(t, uint(0), b.Queued())
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	b := bulkhead.New(
+		bulkhead.WithMaxConcurrent(uint(1)),
+		bulkhead.WithMaxQueue(uint(1)),
+	)
+
+	release := fill(t, b, 1)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(10)*time.Millisecond)
+	defer cancel()
+
+	err := b.RunContext(ctx, func(ctx context.Context) error { return nil })
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestWithPanic(t *testing.T) {
+	b := bulkhead.New(bulkhead.WithMaxConcurrent(uint(1)))
+
+	assert.Panics(t, func() {
+		b.Run(func() error { panic("oops") })
+	})
+
+	// The slot is released despite the panic
+	assert.Equal(t, uint(0), b.InFlight())
+}
+
+func TestWithZeroValues(t *testing.T) {
+	b := bulkhead.New(
+		bulkhead.WithMaxConcurrent(0),
+		bulkhead.WithMaxQueue(0),
+		bulkhead.WithMaxWait(0),
+	)
+
+	release := fill(t, b, 10)
+	defer release()
+
+	err := b.Run(func() error { return nil })
+	assert.Equal(t, bulkhead.ErrBulkheadFull, err)
+}
+
+func TestWithShadowMode(t *testing.T) {
+	b := bulkhead.New(
+		bulkhead.WithMaxConcurrent(uint(1)),
+		bulkhead.WithShadowMode(true),
+	)
+
+	release := fill(t, b, 1)
+	defer release()
+
+	var reported []error
+	ctx := shadow.NewContext(context.Background(), func(err error) {
+		reported = append(reported, err)
+	})
+
+	// Confirm the call is made without a slot, rather than rejected, and
+	// the rejection is reported
+	called := false
+	err := b.RunContext(ctx, func(context.Context) error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, called)
+	assert.Equal(t, []error{bulkhead.ErrBulkheadFull}, reported)
+	assert.Equal(t, uint(1), b.InFlight())
+}
+
+func TestWithShadowModeQueue(t *testing.T) {
+	b := bulkhead.New(
+		bulkhead.WithMaxConcurrent(uint(1)),
+		bulkhead.WithMaxQueue(uint(1)),
+		bulkhead.WithShadowMode(true),
+	)
+
+	var reported []error
+	ctx := shadow.NewContext(context.Background(), func(err error) {
+		reported = append(reported, err)
+	})
+
+	// Confirm the call that would have been queued runs without being
+	// queued or reported
+	release := fill(t, b, 1)
+	defer release()
+	require.NoError(t, b.RunContext(ctx, func(context.Context) error {
+		assert.Equal(t, uint(0), b.Queued())
+		return nil
+	}))
+	assert.Empty(t, reported)
+
+	// Confirm the call is only reported once the queue would be full
+	release = fill(t, b, 1)
+	defer release()
+	require.NoError(t, b.RunContext(ctx, func(context.Context) error { return nil }))
+	assert.Equal(t, []error{bulkhead.ErrBulkheadFull}, reported)
+	assert.Equal(t, uint(0), b.Queued())
+}
@@ -0,0 +1,243 @@
+// Package cache serves the results of calls from memory for a short
+// time, so that repeated reads of the same data do not reach the
+// dependency.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/agschwender/errcat-go/categorizer"
+)
+
+const defaultSize = 1000
+
+// KeyFn returns the key that identifies the data being read by the call
+// made with the context. An empty key indicates the result of the call
+// should not be cached.
+type KeyFn func(ctx context.Context) string
+
+// Cache is a policy that stores the result of each successful call for
+// the TTL, serving it to later calls with the same key rather than
+// making them. Errors in the negative categories are cached too, so that
+// a missing record, for example, is not looked up repeatedly.
+//
+// The cache holds at most size results, evicting the least recently
+// used once full. A cache may be shared by multiple callers that read
+// the same data.
+type Cache struct {
+	categorizer *categorizer.Categorizer
+	keyFn       KeyFn
+	negative    map[string]bool
+	negativeTTL time.Duration
+	now         func() time.Time
+	size        int
+	ttl         time.Duration
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type entry struct {
+	err       error
+	expiresAt time.Time
+	key       string
+	v         interface{}
+}
+
+type option func(*Cache)
+
+// New creates a new Cache that serves the results of successful calls
+// for the TTL, identifying the calls with the key function.
+func New(ttl time.Duration, keyFn KeyFn, opts ...option) *Cache {
+	c := &Cache{
+		categorizer: categorizer.New(),
+		keyFn:       keyFn,
+		now:         time.Now,
+		size:        defaultSize,
+		ttl:         ttl,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithCategorizer defines the categorizer used to determine whether an
+// error is in the negative categories. By default, only the built-in
+// rules are applied.
+func WithCategorizer(cat *categorizer.Categorizer) option {
+	return func(c *Cache) {
+		if cat == nil {
+			cat = categorizer.New()
+		}
+		c.categorizer = cat
+	}
+}
+
+// WithNegativeCaching caches errors in any of the categories for the
+// TTL, which is typically shorter than that of successful results. By
+// default, errors are not cached.
+func WithNegativeCaching(ttl time.Duration, categories ...string) option {
+	return func(c *Cache) {
+		c.negativeTTL = ttl
+		c.negative = make(map[string]bool, len(categories))
+		for _, category := range categories {
+			c.negative[category] = true
+		}
+	}
+}
+
+// WithNow sets the function for getting the current time. This is only
+// useful for testing.
+func WithNow(now func() time.Time) option {
+	return func(c *Cache) {
+		if now == nil {
+			now = time.Now
+		}
+		c.now = now
+	}
+}
+
+// WithSize sets the maximum number of results held by the cache. By
+// default, 1000 results are held.
+func WithSize(size int) option {
+	return func(c *Cache) {
+		if size <= 0 {
+			size = defaultSize
+		}
+		c.size = size
+	}
+}
+
+// Run executes the callback unless its result is cached.
+func (c *Cache) Run(cb func() error) error {
+	return c.RunContext(context.Background(), func(context.Context) error {
+		return cb()
+	})
+}
+
+// RunContext executes the callback unless the result of a call with the
+// same key is cached, in which case the cached error is returned and
+// the cached value is supplied to the Result of the context. Only calls
+// that supply their value to the Result are cached when they succeed.
+func (c *Cache) RunContext(ctx context.Context, cb func(ctx context.Context) error) error {
+	if c == nil {
+		return cb(ctx)
+	}
+
+	key := c.keyFn(ctx)
+	if key == "" {
+		return cb(ctx)
+	}
+
+	r := FromContext(ctx)
+	if e, ok := c.load(key); ok && (e.err != nil || r.accept(e.v)) {
+		r.hit(e.v)
+		return e.err
+	}
+
+	// Successful calls are only cached when they produced a value, since
+	// later calls expecting one would otherwise be served nothing.
+	err := cb(ctx)
+	switch {
+	case err == nil:
+		if v, ok := r.value(); ok {
+			c.store(key, v, nil, c.ttl)
+		}
+	case c.isNegative(err):
+		c.store(key, nil, err, c.negativeTTL)
+	}
+	return err
+}
+
+// Invalidate removes the result of the key, so that the next call with
+// the key is made, e.g. once the data has been written.
+func (c *Cache) Invalidate(key string) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.order.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+// Len returns the number of results held by the cache, including those
+// that have expired but have not been discarded.
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *Cache) isNegative(err error) bool {
+	if len(c.negative) == 0 || c.negativeTTL <= 0 {
+		return false
+	}
+	for _, category := range c.categorizer.Categorize(err) {
+		if c.negative[category] {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cache) load(key string) (*entry, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	cached := e.Value.(*entry)
+	if !c.now().Before(cached.expiresAt) {
+		c.order.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(e)
+	return cached, true
+}
+
+func (c *Cache) store(key string, v interface{}, err error, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cached := &entry{err: err, expiresAt: c.now().Add(ttl), key: key, v: v}
+	if e, ok := c.entries[key]; ok {
+		e.Value = cached
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(cached)
+	for c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*entry).key)
+	}
+}
@@ -0,0 +1,228 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go/cache"
+	"github.com/agschwender/errcat-go/categorizer"
+)
+
+type cacheKey struct{}
+
+func keyFn(ctx context.Context) string {
+	key, _ := ctx.Value(cacheKey{}).(string)
+	return key
+}
+
+var errNotFound = fmt.Errorf("not found")
+
+func withKey(key string) context.Context {
+	return context.WithValue(context.Background(), cacheKey{}, key)
+}
+
+// get runs a call for the key through the cache, returning the value
+// along with whether the call was made.
+func get(c *cache.Cache, key string, v interface{}, err error) (interface{}, bool, error) {
+	r := &cache.Result{}
+	called := false
+	err = c.RunContext(cache.NewContext(withKey(key), r), func(context.Context) error {
+		called = true
+		r.Set(v)
+		return err
+	})
+	return r.Value(), called, err
+}
+
+// hit reports whether a call for the key is served by the cache.
+func hit(c *cache.Cache, key string, r *cache.Result) bool {
+	c.RunContext(cache.NewContext(withKey(key), r), func(context.Context) error {
+		return nil
+	})
+	return r.Cached()
+}
+
+func TestAsNil(t *testing.T) {
+	var c *cache.Cache
+
+	called := false
+	err := c.Run(func() error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, called)
+	assert.Equal(t, 0, c.Len())
+	c.Invalidate("alice")
+
+	var r *cache.Result
+	r.Set("Alice")
+	assert.Nil(t, r.Value())
+	assert.False(t, r.Cached())
+}
+
+func TestRunContext(t *testing.T) {
+	now := time.Now()
+	c := cache.New(time.Second, keyFn, cache.WithNow(func() time.Time { return now }))
+
+	// Confirm the value is cached while fresh
+	v, called, err := get(c, "alice", "Alice", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", v)
+	assert.True(t, called)
+
+	r := &cache.Result{}
+	err = c.RunContext(cache.NewContext(withKey("alice"), r), func(context.Context) error {
+		t.Fatal("the cached call should not be made")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", r.Value())
+	assert.True(t, r.Cached())
+
+	// Confirm errors are not cached by default
+	_, called, err = get(c, "bob", nil, fmt.Errorf("oops"))
+	require.Error(t, err)
+	assert.True(t, called)
+	_, called, _ = get(c, "bob", "Bob", nil)
+	assert.True(t, called)
+
+	// Confirm calls without a key are not cached
+	_, called, _ = get(c, "", "Anonymous", nil)
+	assert.True(t, called)
+	_, called, _ = get(c, "", "Anonymous", nil)
+	assert.True(t, called)
+
+	// Confirm the value expires after the TTL
+	now = now.Add(time.Second)
+	v, called, _ = get(c, "alice", "Alicia", nil)
+	assert.True(t, called)
+	assert.Equal(t, "Alicia", v)
+
+	// Confirm the value is removed when invalidated
+	c.Invalidate("alice")
+	_, called, _ = get(c, "alice", "Alice", nil)
+	assert.True(t, called)
+}
+
+func TestWithNegativeCaching(t *testing.T) {
+	now := time.Now()
+	c := cache.New(time.Minute, keyFn,
+		cache.WithCategorizer(categorizer.New(
+			categorizer.WithRules(categorizer.Is(errNotFound, "not_found")),
+		)),
+		cache.WithNegativeCaching(time.Second, "not_found"),
+		cache.WithNow(func() time.Time { return now }),
+	)
+
+	// Confirm errors in the categories are cached for their own TTL
+	_, called, err := get(c, "alice", nil, errNotFound)
+	assert.Equal(t, errNotFound, err)
+	assert.True(t, called)
+
+	_, called, err = get(c, "alice", "Alice", nil)
+	assert.Equal(t, errNotFound, err)
+	assert.False(t, called)
+
+	now = now.Add(time.Second)
+	v, called, err := get(c, "alice", "Alice", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", v)
+	assert.True(t, called)
+
+	// Confirm other errors are not cached
+	_, _, err = get(c, "bob", nil, fmt.Errorf("oops"))
+	require.Error(t, err)
+	_, called, err = get(c, "bob", "Bob", nil)
+	require.NoError(t, err)
+	assert.True(t, called)
+}
+
+func TestWithSize(t *testing.T) {
+	c := cache.New(time.Minute, keyFn, cache.WithSize(2))
+
+	get(c, "alice", "Alice", nil)
+	get(c, "bob", "Bob", nil)
+
+	// Confirm the least recently used result is evicted
+	_, called, _ := get(c, "alice", "Alice", nil)
+	assert.False(t, called)
+	get(c, "carol", "Carol", nil)
+	assert.Equal(t, 2, c.Len())
+
+	_, called, _ = get(c, "alice", "Alice", nil)
+	assert.False(t, called)
+	_, called, _ = get(c, "bob", "Bob", nil)
+	assert.True(t, called)
+}
+
+func TestWithoutResult(t *testing.T) {
+	now := time.Now()
+	c := cache.New(time.Minute, keyFn,
+		cache.WithCategorizer(categorizer.New(
+			categorizer.WithRules(categorizer.Is(errNotFound, "not_found")),
+		)),
+		cache.WithNegativeCaching(time.Second, "not_found"),
+		cache.WithNow(func() time.Time { return now }),
+	)
+
+	// Confirm a successful call without a value is not cached
+	called := 0
+	for i := 0; i < 2; i++ {
+		err := c.RunContext(withKey("alice"), func(context.Context) error {
+			called++
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, called)
+	assert.Equal(t, 0, c.Len())
+
+	// Confirm errors are still cached
+	for i := 0; i < 2; i++ {
+		err := c.RunContext(withKey("bob"), func(context.Context) error {
+			called++
+			return errNotFound
+		})
+		assert.Equal(t, errNotFound, err)
+	}
+	assert.Equal(t, 3, called)
+}
+
+func TestResultOf(t *testing.T) {
+	c := cache.New(time.Minute, keyFn)
+	get(c, "alice", 42, nil)
+
+	// Confirm a cached value of another type is treated as a miss
+	r := cache.ResultOf[string]()
+	called := false
+	err := c.RunContext(cache.NewContext(withKey("alice"), r), func(context.Context) error {
+		called = true
+		r.Set("Alice")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, called)
+	assert.False(t, r.Cached())
+	assert.Equal(t, "Alice", r.Value())
+
+	// Confirm a cached value of the type is served
+	r = cache.ResultOf[string]()
+	err = c.RunContext(cache.NewContext(withKey("alice"), r), func(context.Context) error {
+		t.Fatal("the cached call should not be made")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, r.Cached())
+	assert.Equal(t, "Alice", r.Value())
+
+	// Confirm a nil value is only accepted by an interface type
+	get(c, "bob", nil, nil)
+	assert.True(t, hit(c, "bob", cache.ResultOf[error]()))
+	assert.False(t, hit(c, "bob", cache.ResultOf[*string]()))
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"sync"
+)
+
+type resultKey struct{}
+
+// Result holds the value produced by a call. It is carried by the
+// context, so that the cache can store the value once the call succeeds
+// and supply the cached value when the call is not made. Without a
+// Result, only the error of a call is cached.
+//
+// Since a timer may return before the callback completes, the value is
+// guarded so that late values are discarded once it has been read.
+type Result struct {
+	accepts func(v interface{}) bool
+
+	lock   sync.Mutex
+	cached bool
+	done   bool
+	set    bool
+	v      interface{}
+}
+
+// ResultOf creates a Result that only accepts cached values of type T.
+// A cached value of another type, stored by a call that produced a
+// different type with the same key, is treated as a miss.
+func ResultOf[T any]() *Result {
+	return &Result{accepts: func(v interface{}) bool {
+		if _, ok := v.(T); ok {
+			return true
+		}
+		// A nil value is only a T when T is an interface type.
+		var zero T
+		return v == nil && interface{}(zero) == nil
+	}}
+}
+
+// NewContext returns a copy of the context carrying the result.
+func NewContext(ctx context.Context, r *Result) context.Context {
+	return context.WithValue(ctx, resultKey{}, r)
+}
+
+// FromContext returns the result carried by the context, if any.
+func FromContext(ctx context.Context) *Result {
+	r, _ := ctx.Value(resultKey{}).(*Result)
+	return r
+}
+
+// Cached indicates whether the value was served by the cache rather
+// than produced by the call.
+func (r *Result) Cached() bool {
+	if r == nil {
+		return false
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.cached
+}
+
+// Set records the value produced by the call, unless it has already
+// been read.
+func (r *Result) Set(v interface{}) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.done {
+		r.set, r.v = true, v
+	}
+}
+
+// Value returns the value of the call, after which later values are
+// discarded.
+func (r *Result) Value() interface{} {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.done = true
+	return r.v
+}
+
+// accept indicates whether the result accepts the cached value.
+func (r *Result) accept(v interface{}) bool {
+	return r == nil || r.accepts == nil || r.accepts(v)
+}
+
+// value returns the value of the call along with whether one was set,
+// after which later values are discarded.
+func (r *Result) value() (interface{}, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.done = true
+	return r.v, r.set
+}
+
+// hit records the value served by the cache.
+func (r *Result) hit(v interface{}) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.cached, r.done, r.set, r.v = true, true, true, v
+}
@@ -1,35 +1,48 @@
 package errcat
 
 import (
+	"context"
 	"strings"
 	"time"
 
 	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/bulkhead"
+	"github.com/agschwender/errcat-go/cache"
+	"github.com/agschwender/errcat-go/categorizer"
 	"github.com/agschwender/errcat-go/fallback"
+	"github.com/agschwender/errcat-go/limiter"
+	"github.com/agschwender/errcat-go/ratelimit"
 	"github.com/agschwender/errcat-go/retrier"
 	"github.com/agschwender/errcat-go/timer"
 )
 
-type call struct {
-	args       map[string]interface{}
-	categories []string
-	duration   time.Duration
-	err        error
-	name       string
-	startedAt  time.Time
-}
-
 type CallFn func() error
 
+// CallContextFn is the context aware variant of CallFn. The supplied
+// context is cancelled when the call times out or the parent context
+// is done.
+type CallContextFn func(ctx context.Context) error
+
 type Caller struct {
 	dependency string
 	key        string
 	name       string
 
-	breaker  *breaker.Breaker
-	fallback *fallback.Fallback
-	retrier  *retrier.Retrier
-	timer    *timer.Timer
+	attemptTimer *timer.Timer
+	breaker      *breaker.Breaker
+	bulkhead     *bulkhead.Bulkhead
+	cache        *cache.Cache
+	categorizer  *categorizer.Categorizer
+	coalescer    *coalescer
+	fallback     *fallback.Fallback
+	hedger       *Hedger
+	hooks        *Hooks
+	labels       map[string]string
+	limiter      *limiter.Limiter
+	policies     []Policy
+	rateLimiter  *ratelimit.RateLimiter
+	retrier      *retrier.Retrier
+	timer        *timer.Timer
 }
 
 func New(dependency, name string) Caller {
@@ -40,18 +53,155 @@ func New(dependency, name string) Caller {
 	}
 }
 
+// With declares the policies the call is run through, in order, such
+// that the first policy is the outermost. This replaces the default
+// pipeline built from WithCache, WithTimeout, WithHedging,
+// WithBulkhead, WithLimiter, WithRateLimiter, WithBreaker, WithRetrier
+// and WithAttemptTimeout, allowing, for example, the rate limiter to be
+// placed inside the retrier so that each attempt consumes from it.
+// Hedging is declared with a Hedger. Custom policies may be mixed with
+// the built-in ones. The fallback is always applied last.
+func (c Caller) With(policies ...Policy) Caller {
+	c.policies = make([]Policy, 0, len(policies))
+	for _, policy := range policies {
+		if policy != nil {
+			c.policies = append(c.policies, policy)
+		}
+	}
+	return c
+}
+
+// WithAttemptTimeout enforces a timeout on each attempt made by the
+// caller, so that a single hung attempt does not consume the whole
+// budget of the call. The attempt timeout is placed inside the retrier,
+// whereas WithTotalTimeout limits the call as a whole. The context of
+// each attempt has the earlier of the two deadlines.
+func (c Caller) WithAttemptTimeout(timeout time.Duration) Caller {
+	c.attemptTimer = timer.New(timeout)
+	return c
+}
+
+// WithAttemptTimer is the equivalent of WithAttemptTimeout for timers
+// that are not static.
+func (c Caller) WithAttemptTimer(t *timer.Timer) Caller {
+	c.attemptTimer = t
+	return c
+}
+
 // WithBreaker attaches a circuit breaker to the caller.
 func (c Caller) WithBreaker(b *breaker.Breaker) Caller {
 	c.breaker = b
 	return c
 }
 
+// WithBulkhead limits the number of concurrent calls made by the
+// caller. The bulkhead may be shared by multiple callers to limit the
+// concurrent calls to a dependency as a whole. The bulkhead runs within
+// the timeout, so time spent waiting for a slot counts against it.
+func (c Caller) WithBulkhead(b *bulkhead.Bulkhead) Caller {
+	c.bulkhead = b
+	return c
+}
+
+// WithCache serves the results of the caller's calls from the cache
+// while they are fresh. The cache runs ahead of every other policy, so
+// a cached result neither waits on nor counts against them. Since the
+// callback is not run when the result is cached, Do should be used to
+// receive the cached value; CallContext only receives the error. When
+// run by the daemon, cached results are recorded as such, so that they
+// do not dilute the error rate of the dependency.
+func (c Caller) WithCache(cc *cache.Cache) Caller {
+	c.cache = cc
+	return c
+}
+
+// WithCategorizer defines the categorizer used to classify the errors
+// of the caller when it is run by the daemon. This takes precedence
+// over the categorizer of the daemon and is useful for dependencies
+// with errors that require their own rules.
+func (c Caller) WithCategorizer(cat *categorizer.Categorizer) Caller {
+	c.categorizer = cat
+	return c
+}
+
+// WithCoalescing indicates concurrent calls that share the key returned
+// by the function should be made once, with each of the calls receiving
+// the result. The call is run through the caller's policies once for
+// the group, whereas the fallback applies to each call. Since the
+// callbacks of the waiting calls are not run, Do should be used to
+// share the value produced by the dependency; CallContext only shares
+// the error. Calls only share a result with calls that expect the same
+// type of value. The shared call is not cancelled with the call that
+// started it, though each call returns once its own context is done.
+// When run by the daemon, the call made is recorded along with the
+// number of calls that received its result.
+func (c Caller) WithCoalescing(keyFn CoalesceKeyFn) Caller {
+	c.coalescer = nil
+	if keyFn != nil {
+		c.coalescer = newCoalescer(keyFn)
+	}
+	return c
+}
+
 // WithFallback defines the fallback behavior for the caller.
 func (c Caller) WithFallback(f *fallback.Fallback) Caller {
 	c.fallback = f
 	return c
 }
 
+// WithHedging indicates the caller should start another attempt if the
+// call has not succeeded after the delay, up to the maximum number of
+// hedged attempts. The first attempt to succeed is used and the context
+// of the others is cancelled. Each hedged attempt is run through the
+// bulkhead, rate limiter, breaker and retrier. Hedging adds load to the
+// dependency and should only be used for idempotent calls.
+func (c Caller) WithHedging(delay time.Duration, maxHedges uint) Caller {
+	c.hedger = nil
+	if maxHedges > 0 {
+		c.hedger = NewHedger(delay, maxHedges)
+	}
+	return c
+}
+
+// WithHooks defines the functions called as the caller's calls
+// progress. When the caller is run by the daemon, these are called
+// ahead of the daemon's hooks.
+func (c Caller) WithHooks(h Hooks) Caller {
+	c.hooks = &h
+	return c
+}
+
+// WithLabels defines the labels reported with every call made by the
+// caller when it is run by the daemon, e.g. the region or table the
+// caller accesses. Labels supplied with an individual call take
+// precedence over these.
+func (c Caller) WithLabels(labels map[string]string) Caller {
+	c.labels = make(map[string]string, len(labels))
+	for k, v := range labels {
+		c.labels[k] = v
+	}
+	return c
+}
+
+// WithLimiter limits the number of concurrent calls made by the caller
+// to a limit that adapts to the health of the dependency. Like the
+// bulkhead, the limiter may be shared by multiple callers and runs
+// ahead of the rate limiter and breaker, so rejected calls are not
+// counted as failures of the dependency.
+func (c Caller) WithLimiter(l *limiter.Limiter) Caller {
+	c.limiter = l
+	return c
+}
+
+// WithRateLimiter limits the rate at which the caller makes calls. The
+// rate limiter may be shared by multiple callers to limit the calls to
+// a dependency as a whole. Each call consumes from the rate limit once,
+// regardless of how many attempts the retrier makes.
+func (c Caller) WithRateLimiter(l *ratelimit.RateLimiter) Caller {
+	c.rateLimiter = l
+	return c
+}
+
 // WithRetrier indicates the caller should be retried in the event of a
 // failure.
 func (c Caller) WithRetrier(r *retrier.Retrier) Caller {
@@ -59,29 +209,161 @@ func (c Caller) WithRetrier(r *retrier.Retrier) Caller {
 	return c
 }
 
-// WithTimeout enforces a timeout on the caller. This method should only
-// be used in those cases where the wrapped dependency does not already
-// provide timeout functionality. This is because this method does not
-// stop the callback from running if it exceeds the timeout; it only
-// ensures that the Call returns in the allotted time, whereas the
-// dependency functionality may provide better cleanup.
+// WithTimeout enforces a timeout on the caller. When using Call, this
+// method should only be used in those cases where the wrapped
+// dependency does not already provide timeout functionality. This is
+// because Call does not stop the callback from running if it exceeds
+// the timeout; it only ensures that the Call returns in the allotted
+// time, whereas the dependency functionality may provide better
+// cleanup. CallContext will additionally cancel the context supplied
+// to the callback once the timeout is exceeded.
 func (c Caller) WithTimeout(timeout time.Duration) Caller {
 	c.timer = timer.New(timeout)
 	return c
 }
 
+// WithTimer enforces a timeout on the caller using the timer, e.g. one
+// created with timer.NewAdaptive whose timeout adapts to the latencies
+// of the dependency. It is the equivalent of WithTimeout for timers
+// that are not static. Each caller should have its own adaptive timer,
+// so that it observes the latencies of its calls alone.
+func (c Caller) WithTimer(t *timer.Timer) Caller {
+	c.timer = t
+	return c
+}
+
+// WithTotalTimeout enforces a timeout on the call as a whole, including
+// every attempt made by the retrier and the delays between them. It is
+// equivalent to WithTimeout. The remaining budget is exposed to the
+// callback as the deadline of its context, which a retrier configured
+// with retrier.WithBudgetFraction uses to skip attempts that are
+// unlikely to complete in time.
+func (c Caller) WithTotalTimeout(timeout time.Duration) Caller {
+	return c.WithTimeout(timeout)
+}
+
+// Timeouts returns the current timeout of the call as a whole and of
+// each attempt, or zero if they are not limited. These vary from call
+// to call when the timers are adaptive.
+func (c Caller) Timeouts() (total, attempt time.Duration) {
+	return c.timer.Timeout(), c.attemptTimer.Timeout()
+}
+
 // Call executes the callback function.
 func (c Caller) Call(cb CallFn) error {
-	err := c.timer.Run(func() error {
-		return c.breaker.Run(func() error {
-			return c.retrier.Run(func() error {
-				return cb()
-			})
-		})
+	return c.CallContext(context.Background(), func(context.Context) error {
+		return cb()
 	})
+}
 
+// CallContext executes the callback function, propagating the context
+// through the caller's policies. Unless declared otherwise with With,
+// these are the cache, timer, hedger, bulkhead, limiter, rate limiter,
+// breaker, retrier and attempt timer.
+func (c Caller) CallContext(ctx context.Context, cb CallContextFn) error {
+	o := c.observe(ctx)
+	err := c.run(ctx, o, cb)
 	if c.fallback.UseFallback(err) {
-		return c.fallback.Call()
+		o.fallback(err)
+		err = c.useFallback(ctx, c.fallback.Use, err)
 	}
+	o.complete(err)
 	return err
 }
+
+// FallbackFn creates a fallback function that executes the callback
+// using the caller, so that a caller with its own policies, e.g. one
+// for a replica, may be used as a tier of a fallback chain:
+//
+//	fallback.Chain(
+//		fallback.NewContext(replica.FallbackFn(getUser), fallback.WithName("replica")),
+//		fallback.New(nil, fallback.WithName("default")),
+//	)
+//
+// The callback receives the context of the failed call, so the deadline
+// of the request is honored.
+func (c Caller) FallbackFn(cb CallContextFn) fallback.FallbackContextFn {
+	return func(ctx context.Context, _ error) error {
+		// The attempts of the fallback caller are not those of the call
+		// that failed, so they are not recorded with it.
+		return c.CallContext(withRecorder(ctx, nil), cb)
+	}
+}
+
+// useFallback uses the fallback in place of the error, recording the
+// tier that produced the result.
+func (c Caller) useFallback(ctx context.Context, use func(context.Context, error) (string, error), err error) error {
+	tier, err := use(ctx, err)
+	if err == nil {
+		recorderFrom(ctx).fellBack(tier)
+	}
+	c.identify(err)
+	return err
+}
+
+// run executes the callback through the caller's policies, leaving the
+// fallback to the caller of this method.
+func (c Caller) run(ctx context.Context, o *observer, cb CallContextFn) error {
+	_, err := c.coalescer.do(ctx, nil, func(ctx context.Context) (interface{}, error) {
+		return nil, c.runPolicies(ctx, o, &cache.Result{}, cb)
+	})
+	return err
+}
+
+// runPolicies executes the callback through the caller's policies. The
+// result receives the value of the call from the callback, or from the
+// cache when the call is not made.
+func (c Caller) runPolicies(ctx context.Context, o *observer, r *cache.Result, cb CallContextFn) error {
+	policies := c.pipeline()
+	wrapped := make([]Policy, len(policies))
+	for i, policy := range policies {
+		wrapped[i] = o.instrument(policy, c.identifying(policy))
+	}
+
+	err := compose(wrapped, o.attempt(recordAttempts(c.injectFaults(cb))))(cache.NewContext(ctx, r))
+	if r.Cached() {
+		recorderFrom(ctx).hit()
+	}
+	return err
+}
+
+// pipeline returns the policies the call is run through. By default,
+// the cache is run ahead of the others so that cached results are
+// served immediately, and the bulkhead and rate limiter are run ahead
+// of the breaker so that rejected calls are not counted as failures of
+// the dependency.
+func (c Caller) pipeline() []Policy {
+	if c.policies != nil {
+		return c.policies
+	}
+
+	policies := make([]Policy, 0, 9)
+	if c.cache != nil {
+		policies = append(policies, c.cache)
+	}
+	if c.timer != nil {
+		policies = append(policies, c.timer)
+	}
+	if c.hedger != nil {
+		policies = append(policies, c.hedger)
+	}
+	if c.bulkhead != nil {
+		policies = append(policies, c.bulkhead)
+	}
+	if c.limiter != nil {
+		policies = append(policies, c.limiter)
+	}
+	if c.rateLimiter != nil {
+		policies = append(policies, c.rateLimiter)
+	}
+	if c.breaker != nil {
+		policies = append(policies, c.breaker)
+	}
+	if c.retrier != nil {
+		policies = append(policies, c.retrier)
+	}
+	if c.attemptTimer != nil {
+		policies = append(policies, c.attemptTimer)
+	}
+	return policies
+}
@@ -1,16 +1,25 @@
 package errcat_test
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/agschwender/errcat-go"
 	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/bulkhead"
+	"github.com/agschwender/errcat-go/cache"
 	"github.com/agschwender/errcat-go/fallback"
+	"github.com/agschwender/errcat-go/limiter"
+	"github.com/agschwender/errcat-go/ratelimit"
 	"github.com/agschwender/errcat-go/retrier"
+	"github.com/agschwender/errcat-go/timer"
 )
 
 func TestCallerWithDefaults(t *testing.T) {
@@ -44,3 +53,679 @@ func TestCallerWithOptions(t *testing.T) {
 	assert.Equal(t, 1, fallbacks)
 
 }
+
+func TestCallerCallContext(t *testing.T) {
+	c := errcat.New("google", "clients.Google.Search").
+		WithRetrier(retrier.New(retrier.WithMaxAttempts(3))).
+		WithTimeout(time.Duration(50) * time.Millisecond)
+
+	// Confirm the callback's context is cancelled on timeout
+	cancelled := make(chan error, 1)
+	err := c.CallContext(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return ctx.Err()
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, timer.ErrTimeout)
+	assert.Equal(t, context.DeadlineExceeded, <-cancelled)
+
+	// Confirm retries stop once the parent context is cancelled
+	counts := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	err = c.CallContext(ctx, func(ctx context.Context) error {
+		counts++
+		cancel()
+		return fmt.Errorf("oops")
+	})
+	require.Error(t, err)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, counts)
+}
+
+func TestCallerWithBulkhead(t *testing.T) {
+	b := breaker.New(breaker.WithMaxFailures(1))
+	c := errcat.New("google", "clients.Google.Search").
+		WithBreaker(b).
+		WithBulkhead(bulkhead.New(bulkhead.WithMaxConcurrent(1)))
+
+	started := make(chan bool)
+	release := make(chan bool)
+	done := make(chan error)
+	go func() {
+		done <- c.Call(func() error {
+			started <- true
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	// Confirm the rejection is not counted by the breaker
+	err := c.Call(func() error { return nil })
+	assert.Equal(t, bulkhead.ErrBulkheadFull, err)
+	assert.Equal(t, breaker.Closed, b.State().Status())
+
+	close(release)
+	require.NoError(t, <-done)
+}
+
+func TestCallerWithLimiter(t *testing.T) {
+	b := breaker.New(breaker.WithMaxFailures(1))
+	l := limiter.NewAIMD(0, limiter.WithInitialLimit(1), limiter.WithMaxLimit(1))
+	c := errcat.New("google", "clients.Google.Search").
+		WithBreaker(b).
+		WithLimiter(l)
+
+	started := make(chan bool)
+	release := make(chan bool)
+	done := make(chan error)
+	go func() {
+		done <- c.Call(func() error {
+			started <- true
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	assert.Equal(t, uint(1), l.InFlight())
+
+	// Confirm the rejection is not counted by the breaker
+	err := c.Call(func() error { return nil })
+	assert.ErrorIs(t, err, limiter.ErrLimitExceeded)
+	assert.Equal(t, breaker.Closed, b.State().Status())
+
+	close(release)
+	require.NoError(t, <-done)
+	assert.Equal(t, uint(0), l.InFlight())
+}
+
+type userKey struct{}
+
+func userKeyFn(ctx context.Context) string {
+	key, _ := ctx.Value(userKey{}).(string)
+	return key
+}
+
+func TestCallerWithCache(t *testing.T) {
+	b := breaker.New(breaker.WithMaxFailures(1))
+	c := errcat.New("mysql", "users.GetName").
+		WithBreaker(b).
+		WithCache(cache.New(time.Minute, userKeyFn))
+	ctx := context.WithValue(context.Background(), userKey{}, "alice")
+
+	v, err := errcat.Do(ctx, c, func(context.Context) (string, error) {
+		return "Alice", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", v)
+
+	// Confirm the cached value is served ahead of the breaker
+	err = c.Call(func() error { return fmt.Errorf("oops") })
+	require.Error(t, err)
+	assert.Equal(t, breaker.Open, b.State().Status())
+
+	v, err = errcat.Do(ctx, c, func(context.Context) (string, error) {
+		t.Fatal("the cached call should not be made")
+		return "", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", v)
+}
+
+func TestCallerWithCacheMismatchedValues(t *testing.T) {
+	c := errcat.New("mysql", "users.GetName").
+		WithCache(cache.New(time.Minute, userKeyFn))
+	ctx := context.WithValue(context.Background(), userKey{}, "alice")
+
+	// Confirm a call that supplies no value is not cached
+	err := c.CallContext(ctx, func(context.Context) error { return nil })
+	require.NoError(t, err)
+
+	counts := 0
+	getName := func(ctx context.Context) (string, error) {
+		counts++
+		return "Alice", nil
+	}
+	v, err := errcat.Do(ctx, c, getName)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", v)
+	assert.Equal(t, 1, counts)
+
+	// Confirm a cached value of another type is treated as a miss
+	ctx = context.WithValue(context.Background(), userKey{}, "bob")
+	_, err = errcat.Do(ctx, c, func(context.Context) (int, error) { return 42, nil })
+	require.NoError(t, err)
+
+	v, err = errcat.Do(ctx, c, getName)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", v)
+	assert.Equal(t, 2, counts)
+}
+
+func TestCallerWithRateLimiter(t *testing.T) {
+	l := ratelimit.NewTokenBucket(1, 1)
+
+	// Confirm the rate limiter is shared between callers and that each
+	// call consumes a single token regardless of retries.
+	first := errcat.New("google", "clients.Google.Search").
+		WithRateLimiter(l).
+		WithRetrier(retrier.New(retrier.WithMaxAttempts(3)))
+	second := errcat.New("google", "clients.Google.Lookup").
+		WithRateLimiter(l)
+
+	counts := 0
+	err := first.Call(func() error {
+		counts++
+		return fmt.Errorf("oops")
+	})
+	require.Error(t, err)
+	assert.Equal(t, "google:clients.Google.Search: retries exhausted after 3 attempts: oops", err.Error())
+	assert.Equal(t, 3, counts)
+
+	err = second.Call(func() error { return nil })
+	assert.Equal(t, ratelimit.ErrRateLimited, err)
+}
+
+func TestCallerWithHedging(t *testing.T) {
+	c := errcat.New("google", "clients.Google.Search").
+		WithHedging(time.Duration(10)*time.Millisecond, 2)
+
+	// Confirm the slow attempt is cancelled once a hedge succeeds
+	var lock sync.Mutex
+	counts := 0
+	cancelled := make(chan error, 1)
+	err := c.CallContext(context.Background(), func(ctx context.Context) error {
+		lock.Lock()
+		counts++
+		attempt := counts
+		lock.Unlock()
+
+		if attempt == 1 {
+			<-ctx.Done()
+			cancelled <- ctx.Err()
+			return ctx.Err()
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, context.Canceled, <-cancelled)
+	assert.Equal(t, 2, counts)
+
+	// Confirm the error is returned once every attempt fails
+	counts = 0
+	err = c.Call(func() error {
+		lock.Lock()
+		counts++
+		lock.Unlock()
+
+		time.Sleep(time.Duration(15) * time.Millisecond)
+		return fmt.Errorf("oops")
+	})
+	require.Error(t, err)
+	assert.Equal(t, "oops", err.Error())
+	assert.Equal(t, 3, counts)
+}
+
+func TestCallerWithHedgingRespectsBreaker(t *testing.T) {
+	b := breaker.New(breaker.WithMaxFailures(1))
+	c := errcat.New("google", "clients.Google.Search").
+		WithBreaker(b).
+		WithHedging(time.Duration(20)*time.Millisecond, 2)
+
+	// The original attempt fails and opens the breaker while the first
+	// hedge is running, so the second hedge is rejected.
+	var lock sync.Mutex
+	counts := 0
+	err := c.Call(func() error {
+		lock.Lock()
+		counts++
+		attempt := counts
+		lock.Unlock()
+
+		if attempt == 1 {
+			time.Sleep(time.Duration(30) * time.Millisecond)
+		} else {
+			time.Sleep(time.Duration(40) * time.Millisecond)
+		}
+		return fmt.Errorf("oops")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 2, counts)
+	assert.Equal(t, breaker.Open, b.State().Status())
+}
+
+func TestCallerWithHedger(t *testing.T) {
+	// Confirm the hedger may be placed inside the retrier, so that each
+	// attempt is hedged
+	c := errcat.New("google", "clients.Google.Search").With(
+		retrier.New(retrier.WithMaxAttempts(2)),
+		errcat.NewHedger(time.Millisecond, 1),
+	)
+
+	var counts int32
+	v, err := errcat.Do(context.Background(), c, func(ctx context.Context) (string, error) {
+		switch atomic.AddInt32(&counts, 1) {
+		case 1:
+			return "", fmt.Errorf("oops")
+		case 2:
+			<-ctx.Done()
+			return "", ctx.Err()
+		default:
+			return "alice", nil
+		}
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", v)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&counts))
+}
+
+func TestCallerWith(t *testing.T) {
+	var order []string
+	policy := func(name string) errcat.Policy {
+		return errcat.PolicyFn(func(ctx context.Context, next func(context.Context) error) error {
+			order = append(order, name)
+			return next(ctx)
+		})
+	}
+
+	// Confirm the policies are run in the declared order
+	c := errcat.New("google", "clients.Google.Search").
+		With(policy("first"), nil, policy("second"), policy("third"))
+	err := c.Call(func() error {
+		order = append(order, "cb")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "third", "cb"}, order)
+
+	// Confirm a timer inside the retrier limits each attempt
+	c = errcat.New("google", "clients.Google.Search").With(
+		retrier.New(retrier.WithMaxAttempts(3)),
+		timer.New(time.Duration(10)*time.Millisecond),
+	)
+	var lock sync.Mutex
+	counts := 0
+	err = c.CallContext(context.Background(), func(ctx context.Context) error {
+		lock.Lock()
+		counts++
+		attempt := counts
+		lock.Unlock()
+
+		if attempt < 3 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	lock.Lock()
+	assert.Equal(t, 3, counts)
+	lock.Unlock()
+
+	// Confirm a policy may reject the call without running the rest of
+	// the pipeline
+	reject := errcat.PolicyFn(func(context.Context, func(context.Context) error) error {
+		return fmt.Errorf("rejected")
+	})
+	c = errcat.New("google", "clients.Google.Search").
+		With(reject, breaker.New()).
+		WithFallback(fallback.New(func() error { return nil }))
+	err = c.Call(func() error {
+		t.Fatal("callback should not be run")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestCallerWithFallbackChain(t *testing.T) {
+	replica := errcat.New("mysql-replica", "users.GetUser").
+		WithBreaker(breaker.New(breaker.WithMaxFailures(1)))
+
+	replicaCalls := 0
+	c := errcat.New("mysql", "users.GetUser").
+		WithFallback(fallback.Chain(
+			fallback.NewContext(replica.FallbackFn(func(ctx context.Context) error {
+				replicaCalls++
+				return fmt.Errorf("replica down")
+			}), fallback.WithName("replica")),
+			fallback.New(func() error { return fmt.Errorf("no default") }, fallback.WithName("default")),
+		))
+
+	// Confirm the error aggregates each tier and identifies the caller
+	err := c.Call(func() error { return fmt.Errorf("oops") })
+	assert.EqualError(t, err, "mysql:users.GetUser: fallbacks exhausted after 2 tiers: no default")
+
+	var chainErr *fallback.ChainError
+	require.ErrorAs(t, err, &chainErr)
+	require.Len(t, chainErr.Errors, 3)
+	assert.EqualError(t, chainErr.Errors[0], "oops")
+	assert.EqualError(t, chainErr.Errors[1], "replica down")
+
+	// Confirm the breaker of the replica caller applies
+	err = c.Call(func() error { return fmt.Errorf("oops") })
+	require.ErrorAs(t, err, &chainErr)
+	assert.ErrorIs(t, chainErr.Errors[1], breaker.ErrBreakerOpen)
+	assert.Equal(t, 1, replicaCalls)
+}
+
+type coalesceKey struct{}
+
+// coalesceByKey returns a key function that coalesces calls by the key
+// in their context, counting the calls it is used for.
+func coalesceByKey(keyed *int32) errcat.CoalesceKeyFn {
+	return func(ctx context.Context) string {
+		atomic.AddInt32(keyed, 1)
+		key, _ := ctx.Value(coalesceKey{}).(string)
+		return key
+	}
+}
+
+// awaitCoalesced waits until the key function has been used for the
+// number of calls, then briefly for the calls to join their group.
+func awaitCoalesced(t *testing.T, keyed *int32, calls int32) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(keyed) == calls
+	}, time.Second, time.Millisecond)
+	time.Sleep(time.Duration(10) * time.Millisecond)
+}
+
+func TestCallerWithCoalescing(t *testing.T) {
+	var keyed int32
+	c := errcat.New("mysql", "users.GetUser").
+		WithRetrier(retrier.New(retrier.WithMaxAttempts(2))).
+		WithCoalescing(coalesceByKey(&keyed))
+	ctx := context.WithValue(context.Background(), coalesceKey{}, "alice")
+
+	// Confirm concurrent calls with the same key are run through the
+	// retrier once and share its result
+	var attempts int32
+	release := make(chan bool)
+	results := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			results <- c.CallContext(ctx, func(context.Context) error {
+				atomic.AddInt32(&attempts, 1)
+				<-release
+				return fmt.Errorf("oops")
+			})
+		}()
+	}
+	awaitCoalesced(t, &keyed, 3)
+	close(release)
+
+	for i := 0; i < 3; i++ {
+		err := <-results
+		require.Error(t, err)
+		assert.Equal(t, "mysql:users.GetUser: retries exhausted after 2 attempts: oops", err.Error())
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	// Confirm later calls are made afresh, as are calls without a key
+	require.NoError(t, c.CallContext(ctx, func(context.Context) error {
+		atomic.AddInt32(&attempts, 1)
+		return nil
+	}))
+	require.NoError(t, c.Call(func() error {
+		atomic.AddInt32(&attempts, 1)
+		return nil
+	}))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&attempts))
+}
+
+func TestCallerWithCoalescingCancelled(t *testing.T) {
+	var keyed int32
+	c := errcat.New("mysql", "users.GetUser").
+		WithCoalescing(coalesceByKey(&keyed))
+	ctx := context.WithValue(context.Background(), coalesceKey{}, "alice")
+
+	release := make(chan bool)
+	cancelled := make(chan error, 1)
+	leaderCtx, cancelLeader := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		done <- c.CallContext(leaderCtx, func(ctx context.Context) error {
+			select {
+			case <-release:
+				return nil
+			case <-ctx.Done():
+				cancelled <- ctx.Err()
+				return ctx.Err()
+			}
+		})
+	}()
+	awaitCoalesced(t, &keyed, 1)
+
+	// Confirm a waiting call returns once its own context is done,
+	// without affecting the call it waited on
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(10)*time.Millisecond)
+	defer cancel()
+	err := c.CallContext(waitCtx, func(context.Context) error { return nil })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Confirm the call that started the group returns once its context
+	// is done, while the shared call continues for the others
+	waiter := make(chan error)
+	go func() {
+		waiter <- c.CallContext(ctx, func(context.Context) error { return nil })
+	}()
+	awaitCoalesced(t, &keyed, 3)
+	cancelLeader()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	close(release)
+	require.NoError(t, <-waiter)
+	assert.Empty(t, cancelled)
+}
+
+func TestCallerWithCoalescingAbandoned(t *testing.T) {
+	var keyed int32
+	c := errcat.New("mysql", "users.GetUser").
+		WithCoalescing(coalesceByKey(&keyed))
+	ctx := context.WithValue(context.Background(), coalesceKey{}, "alice")
+
+	release := make(chan bool)
+	cancelled := make(chan error, 1)
+	leaderCtx, cancelLeader := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		done <- c.CallContext(leaderCtx, func(ctx context.Context) error {
+			<-ctx.Done()
+			cancelled <- ctx.Err()
+			<-release
+			return ctx.Err()
+		})
+	}()
+	awaitCoalesced(t, &keyed, 1)
+	waitCtx, cancel := context.WithCancel(ctx)
+	waiter := make(chan error)
+	go func() {
+		waiter <- c.CallContext(waitCtx, func(context.Context) error { return nil })
+	}()
+	awaitCoalesced(t, &keyed, 2)
+
+	// Confirm the shared call is cancelled once every call has returned
+	cancelLeader()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Empty(t, cancelled)
+	cancel()
+	assert.ErrorIs(t, <-waiter, context.Canceled)
+	assert.ErrorIs(t, <-cancelled, context.Canceled)
+
+	// Confirm later calls are made afresh while the shared call ends
+	called := false
+	require.NoError(t, c.CallContext(ctx, func(context.Context) error {
+		called = true
+		return nil
+	}))
+	assert.True(t, called)
+	close(release)
+}
+
+func TestCallerWithCoalescingDeadline(t *testing.T) {
+	var keyed int32
+	c := errcat.New("mysql", "users.GetUser").
+		WithCoalescing(coalesceByKey(&keyed))
+	ctx := context.WithValue(context.Background(), coalesceKey{}, "alice")
+
+	// Confirm the shared call keeps the deadline of the call that
+	// started it
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	expected, _ := ctx.Deadline()
+	require.NoError(t, c.CallContext(ctx, func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.Equal(t, expected, deadline)
+		return nil
+	}))
+}
+
+func TestCallerWithCoalescingPanic(t *testing.T) {
+	var keyed int32
+	c := errcat.New("mysql", "users.GetUser").
+		WithCoalescing(coalesceByKey(&keyed))
+	ctx := context.WithValue(context.Background(), coalesceKey{}, "alice")
+
+	release := make(chan bool)
+	leader := make(chan bool)
+	waiter := make(chan error)
+	go func() {
+		defer close(leader)
+		assert.Panics(t, func() {
+			c.CallContext(ctx, func(context.Context) error {
+				<-release
+				panic("oops")
+			})
+		})
+	}()
+	awaitCoalesced(t, &keyed, 1)
+	go func() {
+		waiter <- c.CallContext(ctx, func(context.Context) error { return nil })
+	}()
+	awaitCoalesced(t, &keyed, 2)
+
+	// Confirm the panic continues in the call that started the group,
+	// while the waiting call receives it as an error
+	close(release)
+	var panicErr *errcat.PanicError
+	require.ErrorAs(t, <-waiter, &panicErr)
+	assert.Equal(t, "oops", panicErr.Value)
+	<-leader
+}
+
+func TestCallerWithContextFallback(t *testing.T) {
+	errNotFound := fmt.Errorf("not found")
+	var triggers []error
+	c := errcat.New("mysql", "users.GetUser").
+		WithTimeout(time.Duration(10) * time.Millisecond).
+		WithFallback(fallback.NewContext(func(ctx context.Context, err error) error {
+			triggers = append(triggers, err)
+			if err == errNotFound {
+				return err
+			}
+
+			// The fallback honors the deadline of the request
+			_, ok := ctx.Deadline()
+			assert.True(t, ok)
+			return nil
+		}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err := c.CallContext(ctx, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.NoError(t, err)
+
+	err = c.CallContext(ctx, func(context.Context) error { return errNotFound })
+	assert.Equal(t, errNotFound, err)
+
+	require.Len(t, triggers, 2)
+	assert.ErrorIs(t, triggers[0], timer.ErrTimeout)
+	assert.Equal(t, errNotFound, triggers[1])
+}
+
+func TestCallerWithAttemptTimeout(t *testing.T) {
+	c := errcat.New("mysql", "users.GetUser").
+		WithTotalTimeout(time.Second).
+		WithRetrier(retrier.New(retrier.WithMaxAttempts(3))).
+		WithAttemptTimeout(time.Duration(20) * time.Millisecond)
+
+	// Confirm a hung attempt only consumes its own timeout
+	var lock sync.Mutex
+	var deadlines []time.Duration
+	attempts := 0
+	err := c.CallContext(context.Background(), func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+
+		lock.Lock()
+		deadlines = append(deadlines, time.Until(deadline))
+		attempts++
+		attempt := attempts
+		lock.Unlock()
+
+		if attempt == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 2, attempts)
+	for _, d := range deadlines {
+		assert.LessOrEqual(t, d, time.Duration(20)*time.Millisecond)
+	}
+}
+
+func TestCallerWithTotalTimeout(t *testing.T) {
+	c := errcat.New("mysql", "users.GetUser").
+		WithTotalTimeout(time.Duration(50) * time.Millisecond).
+		WithRetrier(retrier.New(
+			retrier.WithMaxAttempts(10),
+			retrier.WithBudgetFraction(1),
+		)).
+		WithAttemptTimeout(time.Duration(30) * time.Millisecond)
+
+	// Confirm the retrier skips the attempt that would not complete in
+	// the remaining budget, rather than letting the call time out.
+	var attempts int32
+	err := c.CallContext(context.Background(), func(ctx context.Context) error {
+		atomic.AddInt32(&attempts, 1)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, timer.ErrTimeout)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	var timeoutErr *timer.TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, time.Duration(30)*time.Millisecond, timeoutErr.Timeout)
+}
+
+func TestCallerTimeouts(t *testing.T) {
+	total, attempt := errcat.New("mysql", "users.GetUser").Timeouts()
+	assert.Equal(t, time.Duration(0), total)
+	assert.Equal(t, time.Duration(0), attempt)
+
+	c := errcat.New("mysql", "users.GetUser").
+		WithTimer(timer.NewAdaptive(time.Second, timer.WithWarmup(1))).
+		WithAttemptTimer(timer.New(time.Duration(100) * time.Millisecond))
+
+	total, attempt = c.Timeouts()
+	assert.Equal(t, time.Second, total)
+	assert.Equal(t, time.Duration(100)*time.Millisecond, attempt)
+
+	// Confirm the adaptive timeout adapts to the calls of the caller
+	require.NoError(t, c.Call(func() error { return nil }))
+	total, _ = c.Timeouts()
+	assert.Less(t, total, time.Second)
+}
@@ -0,0 +1,239 @@
+package categorizer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/bulkhead"
+	"github.com/agschwender/errcat-go/limiter"
+	"github.com/agschwender/errcat-go/ratelimit"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+const (
+	// BreakerOpen indicates the call was rejected by an open circuit
+	// breaker.
+	BreakerOpen = "breaker_open"
+
+	// BulkheadFull indicates the call was rejected by a bulkhead
+	// because too many calls were already running.
+	BulkheadFull = "bulkhead_full"
+
+	// Canceled indicates the call was abandoned by the caller.
+	Canceled = "canceled"
+
+	// ClientError indicates the dependency rejected the request as
+	// invalid, e.g. a 4xx status code.
+	ClientError = "client_error"
+
+	// ConcurrencyLimited indicates the call was rejected by an adaptive
+	// concurrency limiter because too many calls were already running.
+	ConcurrencyLimited = "concurrency_limited"
+
+	// DNS indicates the dependency's host could not be resolved.
+	DNS = "dns"
+
+	// Network indicates a failure at the network level, such as a
+	// refused or reset connection.
+	Network = "network"
+
+	// RateLimited indicates the dependency rejected the request due to
+	// exceeding its quota, e.g. a 429 status code.
+	RateLimited = "rate_limited"
+
+	// ServerError indicates the dependency failed to handle the
+	// request, e.g. a 5xx status code.
+	ServerError = "server_error"
+
+	// TLS indicates a failure establishing a secure connection.
+	TLS = "tls"
+
+	// Throttled indicates the call was rejected by a client side rate
+	// limiter before it reached the dependency.
+	Throttled = "throttled"
+
+	// Timeout indicates the call did not complete in the allotted
+	// time.
+	Timeout = "timeout"
+)
+
+// Rule maps an error to zero or more categories.
+type Rule func(err error) []string
+
+// Categorizer maps errors to categories using an ordered set of rules.
+// The categories of all matching rules are combined.
+type Categorizer struct {
+	defaults bool
+	rules    []Rule
+}
+
+type option func(*Categorizer)
+
+// New creates a new Categorizer with the supplied options. Unless
+// WithoutDefaults is supplied, the built-in rules are always applied
+// after any user defined rules.
+func New(opts ...option) *Categorizer {
+	c := &Categorizer{defaults: true}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.defaults {
+		c.rules = append(c.rules, Defaults()...)
+	}
+
+	return c
+}
+
+// WithRules adds the supplied rules to the categorizer.
+func WithRules(rules ...Rule) option {
+	return func(c *Categorizer) {
+		for _, rule := range rules {
+			if rule != nil {
+				c.rules = append(c.rules, rule)
+			}
+		}
+	}
+}
+
+// WithoutDefaults indicates the built-in rules should not be applied.
+func WithoutDefaults() option {
+	return func(c *Categorizer) {
+		c.defaults = false
+	}
+}
+
+// Defaults returns the built-in rules.
+func Defaults() []Rule {
+	return []Rule{
+		Is(timer.ErrTimeout, Timeout),
+		Is(breaker.ErrBreakerOpen, BreakerOpen),
+		Is(bulkhead.ErrBulkheadFull, BulkheadFull),
+		Is(limiter.ErrLimitExceeded, ConcurrencyLimited),
+		Is(ratelimit.ErrRateLimited, Throttled),
+		Context,
+		Net,
+		StatusCode,
+	}
+}
+
+// Categorize returns the categories for the error. A nil error has no
+// categories.
+func (c *Categorizer) Categorize(err error) []string {
+	if c == nil || err == nil {
+		return nil
+	}
+
+	var categories []string
+	seen := make(map[string]bool)
+	for _, rule := range c.rules {
+		for _, category := range rule(err) {
+			if category == "" || seen[category] {
+				continue
+			}
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+// Is creates a rule that assigns the categories to any error that
+// matches the target using errors.Is.
+func Is(target error, categories ...string) Rule {
+	return Match(func(err error) bool { return errors.Is(err, target) }, categories...)
+}
+
+// Match creates a rule that assigns the categories to any error for
+// which the supplied function returns true.
+func Match(matches func(err error) bool, categories ...string) Rule {
+	return func(err error) []string {
+		if matches(err) {
+			return categories
+		}
+		return nil
+	}
+}
+
+// Context categorizes errors originating from a context.
+func Context(err error) []string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return []string{Canceled}
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return []string{Timeout}
+	default:
+		return nil
+	}
+}
+
+// Net categorizes errors originating from the net package, including
+// DNS and TLS failures.
+func Net(err error) []string {
+	var categories []string
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		categories = append(categories, DNS)
+	}
+
+	if isTLS(err) {
+		categories = append(categories, TLS)
+	}
+
+	// Deadline errors satisfy net.Error, but are not network failures
+	// unless wrapped by one, e.g. a *net.OpError.
+	var netErr net.Error
+	if errors.As(err, &netErr) && !isDeadline(netErr) {
+		categories = append(categories, Network)
+		if netErr.Timeout() {
+			categories = append(categories, Timeout)
+		}
+	} else if len(categories) > 0 {
+		categories = append(categories, Network)
+	}
+
+	return categories
+}
+
+// StatusCode categorizes errors that expose an HTTP status code through
+// a StatusCode method.
+func StatusCode(err error) []string {
+	var coder interface{ StatusCode() int }
+	if !errors.As(err, &coder) {
+		return nil
+	}
+
+	code := coder.StatusCode()
+	switch {
+	case code == http.StatusTooManyRequests:
+		return []string{RateLimited, ClientError}
+	case code >= 400 && code < 500:
+		return []string{ClientError}
+	case code >= 500 && code < 600:
+		return []string{ServerError}
+	default:
+		return nil
+	}
+}
+
+func isDeadline(err error) bool {
+	return err == context.DeadlineExceeded || err == os.ErrDeadlineExceeded
+}
+
+func isTLS(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &recordErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
@@ -0,0 +1,90 @@
+package categorizer_test
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/bulkhead"
+	"github.com/agschwender/errcat-go/categorizer"
+	"github.com/agschwender/errcat-go/limiter"
+	"github.com/agschwender/errcat-go/ratelimit"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+type statusErr int
+
+func (e statusErr) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusErr) StatusCode() int { return int(e) }
+
+func TestAsNil(t *testing.T) {
+	var c *categorizer.Categorizer
+	assert.Nil(t, c.Categorize(fmt.Errorf("oops")))
+}
+
+func TestWithDefaults(t *testing.T) {
+	c := categorizer.New()
+
+	tests := []struct {
+		err        error
+		categories []string
+	}{
+		{nil, nil},
+		{fmt.Errorf("oops"), nil},
+		{timer.ErrTimeout, []string{categorizer.Timeout}},
+		{fmt.Errorf("wrapped: %w", breaker.ErrBreakerOpen), []string{categorizer.BreakerOpen}},
+		{bulkhead.ErrBulkheadFull, []string{categorizer.BulkheadFull}},
+		{limiter.ErrLimitExceeded, []string{categorizer.ConcurrencyLimited}},
+		{ratelimit.ErrRateLimited, []string{categorizer.Throttled}},
+		{context.Canceled, []string{categorizer.Canceled}},
+		{context.DeadlineExceeded, []string{categorizer.Timeout}},
+		{&net.DNSError{Err: "no such host", Name: "example.com"}, []string{categorizer.DNS, categorizer.Network}},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, []string{categorizer.Network}},
+		{&net.DNSError{Err: "i/o timeout", IsTimeout: true}, []string{categorizer.DNS, categorizer.Network, categorizer.Timeout}},
+		{fmt.Errorf("wrapped: %w", x509.UnknownAuthorityError{}), []string{categorizer.TLS, categorizer.Network}},
+		{statusErr(429), []string{categorizer.RateLimited, categorizer.ClientError}},
+		{statusErr(404), []string{categorizer.ClientError}},
+		{statusErr(503), []string{categorizer.ServerError}},
+		{statusErr(302), nil},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.categories, c.Categorize(test.err), "%v", test.err)
+	}
+}
+
+func TestWithRules(t *testing.T) {
+	errDuplicate := errors.New("duplicate entry")
+
+	c := categorizer.New(
+		categorizer.WithRules(
+			categorizer.Is(errDuplicate, "duplicate", categorizer.ClientError),
+			categorizer.Match(func(err error) bool { return err.Error() == "deadlock" }, "deadlock"),
+			nil,
+		),
+	)
+
+	assert.Equal(t, []string{"duplicate", categorizer.ClientError}, c.Categorize(errDuplicate))
+	assert.Equal(t, []string{"deadlock"}, c.Categorize(fmt.Errorf("deadlock")))
+
+	// Built-in rules still apply after the user defined rules and
+	// duplicate categories are removed.
+	err := fmt.Errorf("%w: %v", errDuplicate, statusErr(400))
+	assert.Equal(t, []string{"duplicate", categorizer.ClientError}, c.Categorize(err))
+	assert.Equal(t, []string{categorizer.Timeout}, c.Categorize(timer.ErrTimeout))
+}
+
+func TestWithoutDefaults(t *testing.T) {
+	c := categorizer.New(
+		categorizer.WithoutDefaults(),
+		categorizer.WithRules(categorizer.Is(timer.ErrTimeout, "slow")),
+	)
+
+	assert.Equal(t, []string{"slow"}, c.Categorize(timer.ErrTimeout))
+	assert.Nil(t, c.Categorize(breaker.ErrBreakerOpen))
+}
@@ -0,0 +1,181 @@
+package errcat
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/agschwender/errcat-go/internal/errs"
+)
+
+// CoalesceKeyFn returns the key that identifies the call being made
+// with the context, such that concurrent calls with the same key may
+// share a single result. An empty key indicates the call should not be
+// coalesced.
+type CoalesceKeyFn func(ctx context.Context) string
+
+// coalescer runs concurrent calls that share a key once, handing the
+// result of the call to each of the calls that waited on it.
+type coalescer struct {
+	keyFn CoalesceKeyFn
+
+	lock   sync.Mutex
+	groups map[groupKey]*group
+}
+
+// groupKey identifies the calls that may share a result. Calls share a
+// result only when they expect the same type of value, so that a call
+// made with Do never receives the value of a call made with CallContext
+// or with Do for another type.
+type groupKey struct {
+	key string
+	typ reflect.Type
+}
+
+// group is a call in progress along with the calls waiting on it.
+// Remaining counts the calls still waiting on it, including the one that
+// started it, while waiters counts only those that joined it.
+type group struct {
+	cancel    context.CancelFunc
+	done      chan struct{}
+	err       error
+	panic     interface{}
+	remaining uint
+	v         interface{}
+	waiters   uint
+}
+
+// valuesOnly is a context that carries the values of its parent but not
+// its deadline or cancellation.
+type valuesOnly struct {
+	context.Context
+}
+
+func (valuesOnly) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (valuesOnly) Done() <-chan struct{}       { return nil }
+func (valuesOnly) Err() error                  { return nil }
+
+func newCoalescer(keyFn CoalesceKeyFn) *coalescer {
+	return &coalescer{keyFn: keyFn, groups: make(map[groupKey]*group)}
+}
+
+// detach returns a context that carries the values and deadline of the
+// supplied context but not its cancellation.
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(valuesOnly{ctx}, deadline)
+	}
+	return context.WithCancel(valuesOnly{ctx})
+}
+
+// do runs the callback, unless a call with the same key and type of
+// value is already in progress, in which case its result is returned
+// instead. The callback is run on a context with the deadline, but not
+// the cancellation, of the call that started it, since other calls may
+// come to wait on it; each call, including the one that started it,
+// returns early once its own context is done, without affecting the
+// others. The callback is only cancelled once all of them have returned.
+func (c *coalescer) do(ctx context.Context, typ reflect.Type, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return fn(ctx)
+	}
+
+	key := c.keyFn(ctx)
+	if key == "" {
+		return fn(ctx)
+	}
+	gk := groupKey{key: key, typ: typ}
+
+	c.lock.Lock()
+	if g, ok := c.groups[gk]; ok {
+		g.remaining++
+		g.waiters++
+		c.lock.Unlock()
+
+		if !c.wait(ctx, gk, g, false) {
+			return nil, ctx.Err()
+		}
+		recorderFrom(ctx).waited()
+		return g.v, g.err
+	}
+
+	shared, cancel := detach(ctx)
+	g := &group{cancel: cancel, done: make(chan struct{}), remaining: 1}
+	c.groups[gk] = g
+	c.lock.Unlock()
+
+	go c.run(shared, gk, g, fn)
+
+	if !c.wait(ctx, gk, g, true) {
+		return nil, ctx.Err()
+	}
+	if g.panic != nil {
+		// The panic continues in the call that started the group.
+		panic(g.panic)
+	}
+	return g.v, g.err
+}
+
+// run runs the callback of the group, completing it even if the callback
+// panics, so that the waiters are not left blocked.
+func (c *coalescer) run(ctx context.Context, gk groupKey, g *group, fn func(ctx context.Context) (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			g.err, g.panic = errs.NewPanicError(r), r
+		}
+		c.complete(ctx, gk, g)
+		g.cancel()
+	}()
+
+	g.v, g.err = fn(ctx)
+}
+
+// wait waits for the group to complete, returning false if the context
+// is done first. A waiter that gives up is no longer counted among those
+// the result was shared with, and once every call has given up, the
+// group is cancelled and removed, so that later calls are made afresh.
+func (c *coalescer) wait(ctx context.Context, gk groupKey, g *group, leader bool) bool {
+	select {
+	case <-g.done:
+		return true
+	case <-ctx.Done():
+	}
+
+	c.lock.Lock()
+	pending := c.groups[gk] == g
+	if pending {
+		if !leader {
+			g.waiters--
+		}
+		g.remaining--
+		if g.remaining == 0 {
+			delete(c.groups, gk)
+			g.cancel()
+		}
+	}
+	c.lock.Unlock()
+
+	if pending {
+		return false
+	}
+
+	// The group completed as the context was done, counting this call
+	// among those it shared its result with.
+	<-g.done
+	return true
+}
+
+// complete removes the group, unless every call gave up on it already,
+// so that later calls are made afresh, and releases its waiters.
+func (c *coalescer) complete(ctx context.Context, gk groupKey, g *group) {
+	c.lock.Lock()
+	if c.groups[gk] == g {
+		delete(c.groups, gk)
+	}
+	waiters := g.waiters
+	c.lock.Unlock()
+
+	recorderFrom(ctx).coalesced(waiters)
+	close(g.done)
+}
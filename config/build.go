@@ -0,0 +1,308 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agschwender/errcat-go"
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/categorizer"
+	"github.com/agschwender/errcat-go/fallback"
+	"github.com/agschwender/errcat-go/faults"
+	"github.com/agschwender/errcat-go/retrier"
+)
+
+type builder struct {
+	breakers    map[breakerKey]*breaker.Breaker
+	categorizer *categorizer.Categorizer
+	fallbacks   map[string]fallback.FallbackFn
+	faults      *faults.Injector
+}
+
+// changes are the changes to the breakers and faults shared with the
+// callers in use, which are only applied once the callers created by a
+// build replace them.
+type changes struct {
+	breakers    map[breakerKey]*breaker.Breaker
+	reconfigure []func()
+	rules       []faults.Rule
+}
+
+// breakerKey identifies a breaker across builds. The breakers of
+// individual callers, named by the key of the caller, and shared
+// breakers, named by the configuration, are kept apart so that a shared
+// breaker never aliases the breaker of a caller.
+type breakerKey struct {
+	name   string
+	shared bool
+}
+
+type option func(*builder)
+
+func newBuilder(opts ...option) *builder {
+	b := &builder{
+		breakers:    make(map[breakerKey]*breaker.Breaker),
+		categorizer: categorizer.New(),
+		fallbacks:   make(map[string]fallback.FallbackFn),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// WithCategorizer defines the categorizer used to determine whether an
+// error matches the categories that trigger a fallback. By default,
+// only the built-in rules are applied.
+func WithCategorizer(c *categorizer.Categorizer) option {
+	return func(b *builder) {
+		if c == nil {
+			c = categorizer.New()
+		}
+		b.categorizer = c
+	}
+}
+
+// WithFallback supplies the fallback function for the caller with the
+// key. The configuration of the caller determines when it is used.
+func WithFallback(key string, fn fallback.FallbackFn) option {
+	return func(b *builder) {
+		b.fallbacks[key] = fn
+	}
+}
+
+// WithFaults supplies the injector whose rules are replaced by the
+// faults of the configuration each time its callers are created or, for
+// a FileSource, committed. The injector should also be attached to the
+// daemon with errcat.WithFaults.
+func WithFaults(i *faults.Injector) option {
+	return func(b *builder) {
+		b.faults = i
+	}
+}
+
+// Callers creates the callers described by the configuration, sorted by
+// their key. Callers that reference the same shared breaker use the
+// same breaker.
+func (c *Config) Callers(opts ...option) ([]errcat.Caller, error) {
+	b := newBuilder(opts...)
+	callers, changes, err := b.callers(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.apply(changes); err != nil {
+		return nil, err
+	}
+	return callers, nil
+}
+
+// Register creates the callers described by the configuration and
+// registers them with the daemon, returning their keys in order.
+func (c *Config) Register(d *errcat.Daemon, opts ...option) ([]string, error) {
+	b := newBuilder(opts...)
+	callers, changes, err := b.callers(c)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(callers))
+	for _, caller := range callers {
+		key, err := d.RegisterCaller(caller)
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, b.apply(changes)
+}
+
+// callers creates the callers described by the configuration. The
+// breakers created by previous builds are reused, rather than replaced,
+// so that their state is preserved. Nothing shared with the callers in
+// use is changed; the returned changes must be applied once the callers
+// replace them.
+func (b *builder) callers(c *Config) ([]errcat.Caller, *changes, error) {
+	changes := &changes{breakers: make(map[breakerKey]*breaker.Breaker)}
+
+	var callers []errcat.Caller
+	used := make(map[string]bool, len(b.fallbacks))
+	err := c.each(func(key, dependency, name string, cfg Caller) error {
+		caller := errcat.New(dependency, name)
+
+		switch {
+		case cfg.Breaker != nil:
+			caller = caller.WithBreaker(b.breaker(changes, breakerKey{name: key}, *cfg.Breaker))
+		case cfg.SharedBreaker != "":
+			caller = caller.WithBreaker(b.breaker(changes, breakerKey{name: cfg.SharedBreaker, shared: true}, c.Breakers[cfg.SharedBreaker]))
+		}
+
+		fn, ok := b.fallbacks[key]
+		if cfg.Fallback != nil {
+			if !ok {
+				return fmt.Errorf("no fallback supplied for caller %q", key)
+			}
+			caller = caller.WithFallback(cfg.Fallback.build(fn, b.categorizer))
+		} else if ok {
+			return fmt.Errorf("fallback supplied for caller %q without a fallback configuration", key)
+		}
+		used[key] = true
+
+		if cfg.Retrier != nil {
+			caller = caller.WithRetrier(cfg.Retrier.build())
+		}
+		if cfg.Timeout > 0 {
+			caller = caller.WithTotalTimeout(time.Duration(cfg.Timeout))
+		}
+		if cfg.AttemptTimeout > 0 {
+			caller = caller.WithAttemptTimeout(time.Duration(cfg.AttemptTimeout))
+		}
+
+		callers = append(callers, caller)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, key := range sortedKeys(b.fallbacks) {
+		if !used[key] {
+			return nil, nil, fmt.Errorf("fallback supplied for unknown caller %q", key)
+		}
+	}
+
+	if b.faults == nil && len(c.Faults) > 0 {
+		return nil, nil, fmt.Errorf("faults configured without an injector")
+	}
+	for _, name := range sortedKeys(c.Faults) {
+		changes.rules = append(changes.rules, c.Faults[name].build(name))
+	}
+
+	return callers, changes, nil
+}
+
+// apply replaces the rules of the injector with the faults of the
+// build, then reconfigures the breakers it reused. Breakers that are no
+// longer configured are dropped. Nothing is changed if the rules are
+// invalid.
+func (b *builder) apply(changes *changes) error {
+	if b.faults != nil {
+		if err := b.faults.SetRules(changes.rules...); err != nil {
+			return err
+		}
+	}
+
+	for _, reconfigure := range changes.reconfigure {
+		reconfigure()
+	}
+	b.breakers = changes.breakers
+	return nil
+}
+
+// breaker returns the breaker with the key, creating it if it was not
+// created by a previous build. A breaker created by a previous build is
+// only reconfigured once the changes are applied.
+func (b *builder) breaker(changes *changes, key breakerKey, cfg Breaker) *breaker.Breaker {
+	if br, ok := changes.breakers[key]; ok {
+		return br
+	}
+
+	br, ok := b.breakers[key]
+	if ok {
+		changes.reconfigure = append(changes.reconfigure, func() {
+			cfg.build(key.name, br)
+		})
+	} else {
+		br = cfg.build(key.name, nil)
+	}
+	changes.breakers[key] = br
+	return br
+}
+
+// build creates the breaker described by the configuration or, when one
+// already exists, reconfigures it.
+func (b Breaker) build(name string, br *breaker.Breaker) *breaker.Breaker {
+	// Zero values fall back to the breaker's defaults. The windows are
+	// exclusive, so only the one that is set is applied.
+	window := breaker.WithCountWindow(b.CountWindow)
+	if b.TimeWindow > 0 {
+		window = breaker.WithTimeWindow(time.Duration(b.TimeWindow))
+	}
+
+	opts := options(
+		breaker.WithFailureRate(b.FailureRate),
+		breaker.WithMaxFailures(b.MaxFailures),
+		breaker.WithMaxHalfOpenRequests(b.MaxHalfOpenRequests),
+		breaker.WithMinCalls(b.MinCalls),
+		breaker.WithName(name),
+		breaker.WithShadowMode(b.Shadow),
+		breaker.WithTimeout(time.Duration(b.Timeout)),
+		window,
+	)
+	if br == nil {
+		return breaker.New(opts...)
+	}
+	br.Reconfigure(opts...)
+	return br
+}
+
+// options gathers options into a slice, which allows the unexported
+// option type of a package to be inferred rather than named.
+func options[O any](opts ...O) []O {
+	return opts
+}
+
+func (f Fallback) build(fn fallback.FallbackFn, cat *categorizer.Categorizer) *fallback.Fallback {
+	if len(f.Categories) == 0 {
+		return fallback.New(fn)
+	}
+	return fallback.New(fn, fallback.WithUseFallback(fallback.OnCategoriesOf(cat, f.Categories...)))
+}
+
+func (f Fault) build(name string) faults.Rule {
+	rule := faults.Rule{
+		Delay:       time.Duration(f.Delay),
+		End:         f.End,
+		Key:         f.Key,
+		Name:        name,
+		Panic:       f.Panic,
+		Probability: f.Probability,
+		Start:       f.Start,
+	}
+	if f.Error != "" {
+		rule.Error = errors.New(f.Error)
+	}
+	return rule
+}
+
+func (r Retrier) build() *retrier.Retrier {
+	var backoff retrier.Backoff
+	if r.Backoff != nil {
+		backoff = r.Backoff.build()
+	}
+
+	return retrier.New(
+		retrier.WithBackoff(backoff),
+		retrier.WithBudgetFraction(r.BudgetFraction),
+		retrier.WithMaxAttempts(r.MaxAttempts),
+		retrier.WithMaxElapsedTime(time.Duration(r.MaxElapsedTime)),
+	)
+}
+
+func (b Backoff) build() retrier.Backoff {
+	initial, max, step := time.Duration(b.Initial), time.Duration(b.Max), time.Duration(b.Step)
+	switch b.Type {
+	case Linear:
+		return retrier.Linear(initial, step, max)
+	case Exponential:
+		return retrier.Exponential(initial, max)
+	case FullJitter:
+		return retrier.FullJitter(initial, max)
+	case EqualJitter:
+		return retrier.EqualJitter(initial, max)
+	case DecorrelatedJitter:
+		return retrier.DecorrelatedJitter(initial, max)
+	default:
+		return retrier.Constant(initial)
+	}
+}
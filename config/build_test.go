@@ -0,0 +1,178 @@
+package config_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go"
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/config"
+	"github.com/agschwender/errcat-go/faults"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+const buildConfig = `
+breakers:
+  mysql:
+    max_failures: 2
+dependencies:
+  mysql:
+    callers:
+      users.GetUser:
+        shared_breaker: mysql
+        fallback:
+          categories: [breaker_open]
+      users.ListUsers:
+        shared_breaker: mysql
+        retrier:
+          max_attempts: 2
+  google:
+    callers:
+      clients.Google.Search:
+        timeout: 10ms
+`
+
+func TestCallers(t *testing.T) {
+	c, err := config.Parse([]byte(buildConfig), config.YAML)
+	require.NoError(t, err)
+
+	fallbacks := 0
+	callers, err := c.Callers(config.WithFallback("mysql:users.GetUser", func() error {
+		fallbacks++
+		return nil
+	}))
+	require.NoError(t, err)
+	require.Len(t, callers, 3)
+
+	search, getUser, listUsers := callers[0], callers[1], callers[2]
+
+	// Confirm the retrier of the caller is configured, along with the
+	// breaker it shares with the other mysql caller.
+	attempts := 0
+	for i := 0; i < 2; i++ {
+		err = listUsers.Call(func() error {
+			attempts++
+			return fmt.Errorf("oops")
+		})
+		require.Error(t, err)
+	}
+	assert.Equal(t, 4, attempts)
+
+	err = listUsers.Call(func() error { return nil })
+	assert.ErrorIs(t, err, breaker.ErrBreakerOpen)
+
+	var breakerErr *breaker.BreakerOpenError
+	require.ErrorAs(t, err, &breakerErr)
+	assert.Equal(t, "mysql", breakerErr.Breaker)
+
+	// Confirm the fallback is used for the categories
+	err = getUser.Call(func() error { return nil })
+	require.NoError(t, err)
+	assert.Equal(t, 1, fallbacks)
+
+	// Confirm the timeout is configured
+	err = search.CallContext(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, timer.ErrTimeout)
+}
+
+func TestCallersBreakerNames(t *testing.T) {
+	c, err := config.Parse([]byte(`
+breakers:
+  mysql:users.GetUser:
+    max_failures: 1
+dependencies:
+  mysql:
+    callers:
+      users.GetUser:
+        breaker:
+          max_failures: 5
+      users.ListUsers:
+        shared_breaker: mysql:users.GetUser
+`), config.YAML)
+	require.NoError(t, err)
+
+	callers, err := c.Callers()
+	require.NoError(t, err)
+	require.Len(t, callers, 2)
+	getUser, listUsers := callers[0], callers[1]
+
+	// Confirm a shared breaker named like a caller's key does not share
+	// the caller's own breaker
+	listUsers.Call(func() error { return fmt.Errorf("oops") })
+	err = listUsers.Call(func() error { return nil })
+	assert.ErrorIs(t, err, breaker.ErrBreakerOpen)
+
+	err = getUser.Call(func() error { return fmt.Errorf("oops") })
+	assert.EqualError(t, err, "oops")
+	require.NoError(t, getUser.Call(func() error { return nil }))
+}
+
+func TestCallersFallbackErrors(t *testing.T) {
+	c, err := config.Parse([]byte(buildConfig), config.YAML)
+	require.NoError(t, err)
+
+	_, err = c.Callers()
+	assert.EqualError(t, err, `no fallback supplied for caller "mysql:users.GetUser"`)
+
+	fn := func() error { return nil }
+	_, err = c.Callers(
+		config.WithFallback("mysql:users.GetUser", fn),
+		config.WithFallback("mysql:users.ListUsers", fn),
+	)
+	assert.EqualError(t, err, `fallback supplied for caller "mysql:users.ListUsers" without a fallback configuration`)
+
+	_, err = c.Callers(
+		config.WithFallback("mysql:users.GetUser", fn),
+		config.WithFallback("mysql:users.DeleteUser", fn),
+	)
+	assert.EqualError(t, err, `fallback supplied for unknown caller "mysql:users.DeleteUser"`)
+}
+
+func TestCallersFaults(t *testing.T) {
+	c, err := config.Parse([]byte(buildConfig+"faults:\n  outage:\n    key: \"mysql:*\"\n    error: connection refused\n"), config.YAML)
+	require.NoError(t, err)
+
+	fallback := config.WithFallback("mysql:users.GetUser", func() error { return nil })
+	_, err = c.Callers(fallback)
+	assert.EqualError(t, err, "faults configured without an injector")
+
+	// Confirm the rules of the injector are replaced by the faults
+	i := faults.New()
+	require.NoError(t, i.Add(faults.Rule{Name: "stale", Key: "google:*", Panic: true}))
+	_, err = c.Callers(fallback, config.WithFaults(i))
+	require.NoError(t, err)
+
+	rules := i.Rules()
+	require.Len(t, rules, 1)
+	assert.Equal(t, "outage", rules[0].Name)
+	assert.Equal(t, "mysql:*", rules[0].Key)
+	assert.EqualError(t, rules[0].Error, "connection refused")
+}
+
+func TestRegister(t *testing.T) {
+	c, err := config.Parse([]byte(buildConfig), config.YAML)
+	require.NoError(t, err)
+
+	d := errcat.NewD()
+	keys, err := c.Register(d, config.WithFallback("mysql:users.GetUser", func() error { return nil }))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"google:clients.Google.Search", "mysql:users.GetUser", "mysql:users.ListUsers"}, keys)
+
+	attempts := 0
+	d.Call("mysql:users.ListUsers", func() error {
+		attempts++
+		return fmt.Errorf("oops")
+	})
+	assert.Equal(t, 2, attempts)
+
+	// Confirm registering again reports the duplicate keys
+	_, err = c.Register(d, config.WithFallback("mysql:users.GetUser", func() error { return nil }))
+	assert.Error(t, err)
+}
@@ -0,0 +1,416 @@
+// Package config describes callers and their policies declaratively, so
+// that they may be loaded from a YAML or JSON file rather than being
+// constructed in code.
+//
+// A configuration lists the callers of each dependency along with the
+// settings of their breaker, retrier, timeout and fallback:
+//
+//	breakers:
+//	  mysql:
+//	    max_failures: 10
+//	dependencies:
+//	  mysql:
+//	    callers:
+//	      users.GetUser:
+//	        shared_breaker: mysql
+//	        timeout: 500ms
+//	        attempt_timeout: 200ms
+//	        retrier:
+//	          max_attempts: 3
+//	          budget_fraction: 0.5
+//	          backoff:
+//	            type: exponential
+//	            initial: 50ms
+//	            max: 1s
+//	        fallback:
+//	          categories: [timeout, breaker_open]
+//
+// The callers may be registered with a daemon once, using Register, or
+// supplied by a FileSource so that the daemon reloads them as the file
+// changes.
+//
+// A configuration may also describe faults to inject into the calls of
+// its callers, which are applied to the injector supplied with
+// WithFaults. Supplied by a FileSource, faults may be turned on and off
+// by editing the file:
+//
+//	faults:
+//	  mysql-outage:
+//	    key: "mysql:*"
+//	    probability: 0.5
+//	    delay: 200ms
+//	    error: connection refused
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Format uint8
+
+func (f Format) String() string {
+	switch f {
+	case JSON:
+		return "json"
+	case YAML:
+		return "yaml"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	JSON Format = 0
+	YAML Format = 1
+)
+
+// Backoff types, which correspond to the backoff functions of the
+// retrier package.
+const (
+	Constant           = "constant"
+	Linear             = "linear"
+	Exponential        = "exponential"
+	FullJitter         = "full_jitter"
+	EqualJitter        = "equal_jitter"
+	DecorrelatedJitter = "decorrelated_jitter"
+)
+
+// Config describes the callers of each dependency. Breakers that are
+// shared by multiple callers are described separately and referenced
+// by name.
+type Config struct {
+	Breakers     map[string]Breaker    `json:"breakers,omitempty" yaml:"breakers,omitempty"`
+	Dependencies map[string]Dependency `json:"dependencies" yaml:"dependencies"`
+	Faults       map[string]Fault      `json:"faults,omitempty" yaml:"faults,omitempty"`
+}
+
+// Dependency describes the callers of a dependency, keyed by name.
+type Dependency struct {
+	Callers map[string]Caller `json:"callers" yaml:"callers"`
+}
+
+// Caller describes the policies of a caller. Any policy that is not
+// set is not used.
+type Caller struct {
+	// Breaker describes a breaker used only by this caller, whereas
+	// SharedBreaker names one of the configuration's breakers. Only one
+	// of them may be set.
+	Breaker       *Breaker `json:"breaker,omitempty" yaml:"breaker,omitempty"`
+	SharedBreaker string   `json:"shared_breaker,omitempty" yaml:"shared_breaker,omitempty"`
+
+	Fallback *Fallback `json:"fallback,omitempty" yaml:"fallback,omitempty"`
+	Retrier  *Retrier  `json:"retrier,omitempty" yaml:"retrier,omitempty"`
+
+	// Timeout limits the call as a whole, whereas AttemptTimeout limits
+	// each of the attempts made by the retrier.
+	Timeout        Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	AttemptTimeout Duration `json:"attempt_timeout,omitempty" yaml:"attempt_timeout,omitempty"`
+}
+
+// Breaker describes the settings of a circuit breaker. Zero values use
+// the breaker's defaults.
+type Breaker struct {
+	CountWindow         uint     `json:"count_window,omitempty" yaml:"count_window,omitempty"`
+	FailureRate         float64  `json:"failure_rate,omitempty" yaml:"failure_rate,omitempty"`
+	MaxFailures         uint     `json:"max_failures,omitempty" yaml:"max_failures,omitempty"`
+	MaxHalfOpenRequests uint     `json:"max_half_open_requests,omitempty" yaml:"max_half_open_requests,omitempty"`
+	MinCalls            uint     `json:"min_calls,omitempty" yaml:"min_calls,omitempty"`
+	Shadow              bool     `json:"shadow,omitempty" yaml:"shadow,omitempty"`
+	TimeWindow          Duration `json:"time_window,omitempty" yaml:"time_window,omitempty"`
+	Timeout             Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// Retrier describes the settings of a retrier. Zero values use the
+// retrier's defaults.
+type Retrier struct {
+	Backoff        *Backoff `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	BudgetFraction float64  `json:"budget_fraction,omitempty" yaml:"budget_fraction,omitempty"`
+	MaxAttempts    uint     `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	MaxElapsedTime Duration `json:"max_elapsed_time,omitempty" yaml:"max_elapsed_time,omitempty"`
+}
+
+// Backoff describes the delay between the attempts of a retrier. The
+// initial duration is the delay of a constant or linear backoff and the
+// base of the others. The step only applies to a linear backoff.
+type Backoff struct {
+	Type    string   `json:"type" yaml:"type"`
+	Initial Duration `json:"initial,omitempty" yaml:"initial,omitempty"`
+	Max     Duration `json:"max,omitempty" yaml:"max,omitempty"`
+	Step    Duration `json:"step,omitempty" yaml:"step,omitempty"`
+}
+
+// Fallback describes when the fallback of a caller is used. The
+// fallback itself must be supplied in code. When no categories are
+// listed, the fallback is used for any error.
+type Fallback struct {
+	Categories []string `json:"categories,omitempty" yaml:"categories,omitempty"`
+}
+
+// Fault describes a fault injected into the calls of the callers whose
+// keys match the key, which may be a pattern, e.g. "mysql:*". The fault
+// delays the call, returns an error with the message in place of it or
+// panics, in that order. See faults.Rule.
+type Fault struct {
+	Key         string    `json:"key" yaml:"key"`
+	Probability float64   `json:"probability,omitempty" yaml:"probability,omitempty"`
+	Delay       Duration  `json:"delay,omitempty" yaml:"delay,omitempty"`
+	Error       string    `json:"error,omitempty" yaml:"error,omitempty"`
+	Panic       bool      `json:"panic,omitempty" yaml:"panic,omitempty"`
+	Start       time.Time `json:"start,omitempty" yaml:"start,omitempty"`
+	End         time.Time `json:"end,omitempty" yaml:"end,omitempty"`
+}
+
+// Load reads the configuration from the file, using its extension to
+// determine whether it is JSON or YAML.
+func Load(path string) (*Config, error) {
+	format, err := formatOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func formatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	default:
+		return 0, fmt.Errorf("unable to determine the format of %s: must end in .json, .yaml or .yml", path)
+	}
+}
+
+// Parse decodes and validates the configuration. Unknown fields are
+// rejected so that typos do not go unnoticed.
+func Parse(data []byte, format Format) (*Config, error) {
+	c := &Config{}
+
+	switch format {
+	case JSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(c); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+	case YAML:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil {
+			return nil, fmt.Errorf("invalid yaml: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %s", format)
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the configuration, returning an error that
+// identifies the first invalid setting found.
+func (c *Config) Validate() error {
+	for _, name := range sortedKeys(c.Breakers) {
+		if err := c.Breakers[name].validate(); err != nil {
+			return fmt.Errorf("breakers.%s.%w", name, err)
+		}
+	}
+
+	for _, dependency := range sortedKeys(c.Dependencies) {
+		if dependency == "" {
+			return fmt.Errorf("dependencies: name must not be empty")
+		}
+		if strings.Contains(dependency, ":") {
+			return fmt.Errorf("dependencies.%s: name must not contain \":\"", dependency)
+		}
+
+		callers := c.Dependencies[dependency].Callers
+		for _, name := range sortedKeys(callers) {
+			if name == "" {
+				return fmt.Errorf("dependencies.%s.callers: name must not be empty", dependency)
+			}
+			if err := c.validateCaller(callers[name]); err != nil {
+				return fmt.Errorf("dependencies.%s.callers.%s.%w", dependency, name, err)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(c.Faults) {
+		if name == "" {
+			return fmt.Errorf("faults: name must not be empty")
+		}
+		if err := c.Faults[name].validate(); err != nil {
+			return fmt.Errorf("faults.%s.%w", name, err)
+		}
+	}
+	return nil
+}
+
+// each calls the function with each of the callers described by the
+// configuration, sorted by their key.
+func (c *Config) each(fn func(key, dependency, name string, caller Caller) error) error {
+	for _, dependency := range sortedKeys(c.Dependencies) {
+		callers := c.Dependencies[dependency].Callers
+		for _, name := range sortedKeys(callers) {
+			if err := fn(Key(dependency, name), dependency, name, callers[name]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Key returns the key of the caller with the dependency and name. This
+// matches the key returned when registering the caller with the daemon.
+func Key(dependency, name string) string {
+	return strings.Join([]string{dependency, name}, ":")
+}
+
+func (c *Config) validateCaller(caller Caller) error {
+	if caller.Breaker != nil && caller.SharedBreaker != "" {
+		return fmt.Errorf("breaker: must not be set along with shared_breaker")
+	}
+	if caller.Breaker != nil {
+		if err := caller.Breaker.validate(); err != nil {
+			return fmt.Errorf("breaker.%w", err)
+		}
+	}
+	if caller.SharedBreaker != "" {
+		if _, ok := c.Breakers[caller.SharedBreaker]; !ok {
+			return fmt.Errorf("shared_breaker: unknown breaker %q", caller.SharedBreaker)
+		}
+	}
+	if caller.Fallback != nil {
+		for i, category := range caller.Fallback.Categories {
+			if category == "" {
+				return fmt.Errorf("fallback.categories[%d]: must not be empty", i)
+			}
+		}
+	}
+	if caller.Retrier != nil {
+		if err := caller.Retrier.validate(); err != nil {
+			return fmt.Errorf("retrier.%w", err)
+		}
+	}
+	if caller.Timeout < 0 {
+		return fmt.Errorf("timeout: must not be negative")
+	}
+	if caller.AttemptTimeout < 0 {
+		return fmt.Errorf("attempt_timeout: must not be negative")
+	}
+	return nil
+}
+
+func (b Breaker) validate() error {
+	if b.CountWindow > 0 && b.TimeWindow > 0 {
+		return fmt.Errorf("count_window: must not be set along with time_window")
+	}
+	if b.FailureRate < 0 || b.FailureRate > 1 {
+		return fmt.Errorf("failure_rate: must be between 0 and 1, got %v", b.FailureRate)
+	}
+	if b.TimeWindow < 0 {
+		return fmt.Errorf("time_window: must not be negative")
+	}
+	if b.Timeout < 0 {
+		return fmt.Errorf("timeout: must not be negative")
+	}
+	return nil
+}
+
+func (f Fault) validate() error {
+	if f.Key == "" {
+		return fmt.Errorf("key: must be set")
+	}
+	if _, err := path.Match(f.Key, ""); err != nil {
+		return fmt.Errorf("key: invalid pattern %q", f.Key)
+	}
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("probability: must be between 0 and 1, got %v", f.Probability)
+	}
+	if f.Delay < 0 {
+		return fmt.Errorf("delay: must not be negative")
+	}
+	if f.Delay == 0 && f.Error == "" && !f.Panic {
+		return fmt.Errorf("delay: must be set unless error or panic is")
+	}
+	if !f.Start.IsZero() && !f.End.IsZero() && !f.End.After(f.Start) {
+		return fmt.Errorf("end: must be after start")
+	}
+	return nil
+}
+
+func (r Retrier) validate() error {
+	if r.Backoff != nil {
+		if err := r.Backoff.validate(); err != nil {
+			return fmt.Errorf("backoff.%w", err)
+		}
+	}
+	if r.BudgetFraction < 0 {
+		return fmt.Errorf("budget_fraction: must not be negative")
+	}
+	if r.MaxElapsedTime < 0 {
+		return fmt.Errorf("max_elapsed_time: must not be negative")
+	}
+	return nil
+}
+
+func (b Backoff) validate() error {
+	switch b.Type {
+	case Constant, Linear, Exponential, FullJitter, EqualJitter, DecorrelatedJitter:
+	case "":
+		return fmt.Errorf("type: must be set")
+	default:
+		return fmt.Errorf("type: unknown backoff %q", b.Type)
+	}
+
+	if b.Initial < 0 {
+		return fmt.Errorf("initial: must not be negative")
+	}
+	if b.Max < 0 {
+		return fmt.Errorf("max: must not be negative")
+	}
+	if b.Max > 0 && b.Max < b.Initial {
+		return fmt.Errorf("max: must not be less than initial")
+	}
+	if b.Step < 0 {
+		return fmt.Errorf("step: must not be negative")
+	}
+	if b.Step > 0 && b.Type != Linear {
+		return fmt.Errorf("step: only applies to the %s backoff", Linear)
+	}
+	if b.Type != Constant && b.Initial == 0 {
+		return fmt.Errorf("initial: must be set for the %s backoff", b.Type)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
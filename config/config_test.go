@@ -0,0 +1,294 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go/config"
+)
+
+const yamlConfig = `
+breakers:
+  mysql:
+    max_failures: 10
+    timeout: 30s
+dependencies:
+  mysql:
+    callers:
+      users.GetUser:
+        shared_breaker: mysql
+        timeout: 500ms
+        attempt_timeout: 200ms
+        retrier:
+          max_attempts: 3
+          max_elapsed_time: 2s
+          budget_fraction: 0.5
+          backoff:
+            type: exponential
+            initial: 50ms
+            max: 1s
+        fallback:
+          categories: [timeout, breaker_open]
+  google:
+    callers:
+      clients.Google.Search:
+        breaker:
+          count_window: 100
+          failure_rate: 0.25
+          shadow: true
+faults:
+  mysql-outage:
+    key: "mysql:*"
+    probability: 0.5
+    delay: 200ms
+    error: connection refused
+    start: 2024-01-01T00:00:00Z
+    end: 2024-01-01T01:00:00Z
+`
+
+const jsonConfig = `{
+  "breakers": {"mysql": {"max_failures": 10, "timeout": "30s"}},
+  "dependencies": {
+    "mysql": {
+      "callers": {
+        "users.GetUser": {
+          "shared_breaker": "mysql",
+          "timeout": "500ms",
+          "attempt_timeout": "200ms",
+          "retrier": {
+            "max_attempts": 3,
+            "max_elapsed_time": "2s",
+            "budget_fraction": 0.5,
+            "backoff": {"type": "exponential", "initial": "50ms", "max": "1s"}
+          },
+          "fallback": {"categories": ["timeout", "breaker_open"]}
+        }
+      }
+    },
+    "google": {
+      "callers": {
+        "clients.Google.Search": {
+          "breaker": {"count_window": 100, "failure_rate": 0.25, "shadow": true}
+        }
+      }
+    }
+  },
+  "faults": {
+    "mysql-outage": {
+      "key": "mysql:*",
+      "probability": 0.5,
+      "delay": "200ms",
+      "error": "connection refused",
+      "start": "2024-01-01T00:00:00Z",
+      "end": "2024-01-01T01:00:00Z"
+    }
+  }
+}`
+
+func expectedConfig() *config.Config {
+	return &config.Config{
+		Breakers: map[string]config.Breaker{
+			"mysql": {MaxFailures: 10, Timeout: config.Duration(30 * time.Second)},
+		},
+		Dependencies: map[string]config.Dependency{
+			"mysql": {Callers: map[string]config.Caller{
+				"users.GetUser": {
+					SharedBreaker:  "mysql",
+					Timeout:        config.Duration(500 * time.Millisecond),
+					AttemptTimeout: config.Duration(200 * time.Millisecond),
+					Retrier: &config.Retrier{
+						BudgetFraction: 0.5,
+						MaxAttempts:    3,
+						MaxElapsedTime: config.Duration(2 * time.Second),
+						Backoff: &config.Backoff{
+							Type:    config.Exponential,
+							Initial: config.Duration(50 * time.Millisecond),
+							Max:     config.Duration(time.Second),
+						},
+					},
+					Fallback: &config.Fallback{Categories: []string{"timeout", "breaker_open"}},
+				},
+			}},
+			"google": {Callers: map[string]config.Caller{
+				"clients.Google.Search": {
+					Breaker: &config.Breaker{CountWindow: 100, FailureRate: 0.25, Shadow: true},
+				},
+			}},
+		},
+		Faults: map[string]config.Fault{
+			"mysql-outage": {
+				Key:         "mysql:*",
+				Probability: 0.5,
+				Delay:       config.Duration(200 * time.Millisecond),
+				Error:       "connection refused",
+				Start:       time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+				End:         time.Date(2024, time.January, 1, 1, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	c, err := config.Parse([]byte(yamlConfig), config.YAML)
+	require.NoError(t, err)
+	assert.Equal(t, expectedConfig(), c)
+
+	c, err = config.Parse([]byte(jsonConfig), config.JSON)
+	require.NoError(t, err)
+	assert.Equal(t, expectedConfig(), c)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name:   "unknown field",
+			config: "dependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        timout: 1s\n",
+			err:    "invalid yaml: yaml: unmarshal errors:\n  line 5: field timout not found in type config.Caller",
+		},
+		{
+			name:   "invalid duration",
+			config: "dependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        timeout: soon\n",
+			err:    "invalid yaml: line 5: invalid duration \"soon\"",
+		},
+		{
+			name:   "negative timeout",
+			config: "dependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        timeout: -1s\n",
+			err:    "dependencies.mysql.callers.users.GetUser.timeout: must not be negative",
+		},
+		{
+			name:   "negative attempt timeout",
+			config: "dependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        attempt_timeout: -1s\n",
+			err:    "dependencies.mysql.callers.users.GetUser.attempt_timeout: must not be negative",
+		},
+		{
+			name:   "negative budget fraction",
+			config: "dependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        retrier:\n          budget_fraction: -0.5\n",
+			err:    "dependencies.mysql.callers.users.GetUser.retrier.budget_fraction: must not be negative",
+		},
+		{
+			name:   "dependency with separator",
+			config: "dependencies:\n  my:sql:\n    callers: {}\n",
+			err:    "dependencies.my:sql: name must not contain \":\"",
+		},
+		{
+			name:   "unknown shared breaker",
+			config: "dependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        shared_breaker: users\n",
+			err:    "dependencies.mysql.callers.users.GetUser.shared_breaker: unknown breaker \"users\"",
+		},
+		{
+			name:   "breaker and shared breaker",
+			config: "breakers:\n  users: {}\ndependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        shared_breaker: users\n        breaker: {}\n",
+			err:    "dependencies.mysql.callers.users.GetUser.breaker: must not be set along with shared_breaker",
+		},
+		{
+			name:   "invalid failure rate",
+			config: "breakers:\n  users:\n    failure_rate: 1.5\ndependencies: {}\n",
+			err:    "breakers.users.failure_rate: must be between 0 and 1, got 1.5",
+		},
+		{
+			name:   "both windows",
+			config: "dependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        breaker:\n          count_window: 10\n          time_window: 1m\n",
+			err:    "dependencies.mysql.callers.users.GetUser.breaker.count_window: must not be set along with time_window",
+		},
+		{
+			name:   "unknown backoff",
+			config: "dependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        retrier:\n          backoff:\n            type: fibonacci\n",
+			err:    "dependencies.mysql.callers.users.GetUser.retrier.backoff.type: unknown backoff \"fibonacci\"",
+		},
+		{
+			name:   "step without linear backoff",
+			config: "dependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        retrier:\n          backoff:\n            type: exponential\n            initial: 10ms\n            step: 10ms\n",
+			err:    "dependencies.mysql.callers.users.GetUser.retrier.backoff.step: only applies to the linear backoff",
+		},
+		{
+			name:   "max less than initial",
+			config: "dependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        retrier:\n          backoff:\n            type: linear\n            initial: 1s\n            max: 10ms\n",
+			err:    "dependencies.mysql.callers.users.GetUser.retrier.backoff.max: must not be less than initial",
+		},
+		{
+			name:   "empty fallback category",
+			config: "dependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        fallback:\n          categories: [timeout, \"\"]\n",
+			err:    "dependencies.mysql.callers.users.GetUser.fallback.categories[1]: must not be empty",
+		},
+		{
+			name:   "fault without key",
+			config: "dependencies: {}\nfaults:\n  outage:\n    error: oops\n",
+			err:    "faults.outage.key: must be set",
+		},
+		{
+			name:   "fault with invalid key",
+			config: "dependencies: {}\nfaults:\n  outage:\n    key: \"mysql:[\"\n    error: oops\n",
+			err:    "faults.outage.key: invalid pattern \"mysql:[\"",
+		},
+		{
+			name:   "fault with invalid probability",
+			config: "dependencies: {}\nfaults:\n  outage:\n    key: mysql:users.GetUser\n    probability: 2\n    error: oops\n",
+			err:    "faults.outage.probability: must be between 0 and 1, got 2",
+		},
+		{
+			name:   "fault without effect",
+			config: "dependencies: {}\nfaults:\n  outage:\n    key: mysql:users.GetUser\n",
+			err:    "faults.outage.delay: must be set unless error or panic is",
+		},
+		{
+			name:   "fault ending before start",
+			config: "dependencies: {}\nfaults:\n  outage:\n    key: mysql:users.GetUser\n    panic: true\n    start: 2024-01-01T01:00:00Z\n    end: 2024-01-01T00:00:00Z\n",
+			err:    "faults.outage.end: must be after start",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := config.Parse([]byte(test.config), config.YAML)
+			assert.EqualError(t, err, test.err)
+		})
+	}
+
+	// Confirm JSON is decoded strictly too
+	_, err := config.Parse([]byte(`{"dependencies": {}, "breaker": {}}`), config.JSON)
+	assert.EqualError(t, err, "invalid json: json: unknown field \"breaker\"")
+
+	_, err = config.Parse([]byte(`{"dependencies": {"mysql": {"callers": {"users.GetUser": {"timeout": 10}}}}}`), config.JSON)
+	assert.EqualError(t, err, "invalid json: duration must be a string, e.g. \"500ms\"")
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "errcat.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(yamlConfig), 0o644))
+	c, err := config.Load(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, expectedConfig(), c)
+
+	jsonPath := filepath.Join(dir, "errcat.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(jsonConfig), 0o644))
+	c, err = config.Load(jsonPath)
+	require.NoError(t, err)
+	assert.Equal(t, expectedConfig(), c)
+
+	// Confirm the path is included in errors
+	invalidPath := filepath.Join(dir, "invalid.yml")
+	require.NoError(t, os.WriteFile(invalidPath, []byte("dependencies:\n  my:sql: {}\n"), 0o644))
+	_, err = config.Load(invalidPath)
+	assert.EqualError(t, err, invalidPath+": dependencies.my:sql: name must not contain \":\"")
+
+	_, err = config.Load(filepath.Join(dir, "errcat.toml"))
+	assert.Error(t, err)
+
+	_, err = config.Load(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, "mysql:users.GetUser", config.Key("mysql", "users.GetUser"))
+}
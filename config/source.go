@@ -0,0 +1,100 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/agschwender/errcat-go"
+)
+
+// FileSource supplies the callers described by a configuration file to
+// a daemon, allowing their policies to be changed by editing the file.
+// The breakers of the callers are reused across reloads, so an open
+// breaker remains open after its thresholds are changed.
+//
+//	d := errcat.NewD(errcat.WithConfigSource(config.NewFileSource("errcat.yaml"), 0))
+//
+// Changes to the breakers and faults only take effect, and the file is
+// only considered loaded, once the daemon commits the callers of a
+// reload.
+type FileSource struct {
+	builder *builder
+	path    string
+
+	lock    sync.Mutex
+	data    []byte
+	pending *load
+}
+
+// load is a load of the file awaiting commit.
+type load struct {
+	changes *changes
+	data    []byte
+}
+
+var _ errcat.ConfigCommitter = (*FileSource)(nil)
+
+// NewFileSource creates a source for the configuration file, using its
+// extension to determine whether it is JSON or YAML. The options are
+// applied to the callers of every load.
+func NewFileSource(path string, opts ...option) *FileSource {
+	return &FileSource{builder: newBuilder(opts...), path: path}
+}
+
+// Callers reads the configuration file and creates its callers. When
+// the file has not changed since the last load was committed, no
+// callers are returned. An invalid file is reported each time it is
+// read, until it is fixed.
+func (s *FileSource) Callers(ctx context.Context) ([]errcat.Caller, bool, error) {
+	format, err := formatOf(s.path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.pending = nil
+	if s.data != nil && bytes.Equal(s.data, data) {
+		return nil, false, nil
+	}
+
+	c, err := Parse(data, format)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", s.path, err)
+	}
+
+	callers, changes, err := s.builder.callers(c)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.pending = &load{changes: changes, data: data}
+	return callers, true, nil
+}
+
+// Commit applies the changes to the breakers and faults made by the
+// last call to Callers, once its callers are in use.
+func (s *FileSource) Commit() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.pending == nil {
+		return nil
+	}
+
+	pending := s.pending
+	s.pending = nil
+	if err := s.builder.apply(pending.changes); err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.data = pending.data
+	return nil
+}
@@ -0,0 +1,136 @@
+package config_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go"
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/config"
+	"github.com/agschwender/errcat-go/faults"
+)
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errcat.yaml")
+	write := func(maxFailures, maxAttempts int) {
+		data := fmt.Sprintf(
+			"breakers:\n  mysql:\n    max_failures: %d\ndependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        shared_breaker: mysql\n        retrier:\n          max_attempts: %d\n",
+			maxFailures,
+			maxAttempts,
+		)
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+
+	write(1, 1)
+	src := config.NewFileSource(path)
+
+	callers, changed, err := src.Callers(context.Background())
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Len(t, callers, 1)
+	require.NoError(t, src.Commit())
+
+	// Open the breaker
+	err = callers[0].Call(func() error { return fmt.Errorf("oops") })
+	require.Error(t, err)
+	err = callers[0].Call(func() error { return nil })
+	require.ErrorIs(t, err, breaker.ErrBreakerOpen)
+
+	// Confirm nothing is returned when the file is unchanged
+	callers, changed, err = src.Callers(context.Background())
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Nil(t, callers)
+
+	// Confirm the breaker remains open after it is reconfigured
+	write(5, 3)
+	callers, changed, err = src.Callers(context.Background())
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Len(t, callers, 1)
+	require.NoError(t, src.Commit())
+
+	err = callers[0].Call(func() error { return nil })
+	assert.ErrorIs(t, err, breaker.ErrBreakerOpen)
+
+	// Confirm invalid files are reported until they are fixed
+	require.NoError(t, os.WriteFile(path, []byte("dependencies:\n  my:sql: {}\n"), 0o644))
+	for i := 0; i < 2; i++ {
+		_, _, err = src.Callers(context.Background())
+		assert.EqualError(t, err, path+": dependencies.my:sql: name must not contain \":\"")
+	}
+
+	// Confirm a load is returned again until it is committed
+	write(10, 3)
+	for i := 0; i < 2; i++ {
+		_, changed, err = src.Callers(context.Background())
+		require.NoError(t, err)
+		assert.True(t, changed)
+	}
+	require.NoError(t, src.Commit())
+
+	_, changed, err = src.Callers(context.Background())
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestFileSourceFailedReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errcat.yaml")
+	write := func(data string) {
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+
+	write("breakers:\n  mysql:\n    max_failures: 5\ndependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        shared_breaker: mysql\n")
+	injector := faults.New()
+	d := errcat.NewD(errcat.WithConfigSource(config.NewFileSource(path, config.WithFaults(injector)), time.Hour))
+	require.NoError(t, d.Reload(context.Background()))
+
+	// Confirm a reload that fails leaves the breakers and faults as they
+	// were
+	write("breakers:\n  mysql:\n    max_failures: 1\ndependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        shared_breaker: mysql\n      users.ListUsers:\n        fallback: {}\nfaults:\n  outage:\n    key: \"mysql:*\"\n    error: connection refused\n")
+	assert.EqualError(t, d.Reload(context.Background()), "reload failed: "+path+": no fallback supplied for caller \"mysql:users.ListUsers\"")
+	assert.Empty(t, injector.Rules())
+
+	for i := 0; i < 2; i++ {
+		err := d.Call("mysql:users.GetUser", func() error { return fmt.Errorf("oops") })
+		assert.EqualError(t, err, "oops")
+	}
+
+	// Confirm the file is loaded once it is fixed
+	write("breakers:\n  mysql:\n    max_failures: 1\ndependencies:\n  mysql:\n    callers:\n      users.GetUser:\n        shared_breaker: mysql\nfaults:\n  outage:\n    key: \"mysql:*\"\n    error: connection refused\n")
+	require.NoError(t, d.Reload(context.Background()))
+	assert.Len(t, injector.Rules(), 1)
+}
+
+func TestFileSourceAppliesSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errcat.json")
+	write := func(maxAttempts int) {
+		data := fmt.Sprintf(
+			`{"dependencies": {"mysql": {"callers": {"users.GetUser": {"retrier": {"max_attempts": %d}}}}}}`,
+			maxAttempts,
+		)
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+
+	src := config.NewFileSource(path)
+	for _, maxAttempts := range []int{2, 4} {
+		write(maxAttempts)
+		callers, _, err := src.Callers(context.Background())
+		require.NoError(t, err)
+		require.Len(t, callers, 1)
+
+		attempts := 0
+		callers[0].Call(func() error {
+			attempts++
+			return fmt.Errorf("oops")
+		})
+		assert.Equal(t, maxAttempts, attempts)
+	}
+}
@@ -5,14 +5,20 @@ import (
 	"fmt"
 	"log"
 	"net/url"
+	"runtime/debug"
+	"sync"
 	"time"
 
 	errcatapi "github.com/agschwender/errcat-go/api"
+	"github.com/agschwender/errcat-go/categorizer"
+	"github.com/agschwender/errcat-go/faults"
 )
 
 const bufferSize = 100
 const tickerDuration = time.Duration(15) * time.Second
 
+type labelsKey struct{}
+
 // Daemon is the background processor that will collect all calls and
 // send them to the errcat server.
 type Daemon struct {
@@ -20,19 +26,35 @@ type Daemon struct {
 	env     string
 	service string
 
-	callCh   chan errcatapi.Call
-	client   errcatapi.Client
-	ctx      context.Context
-	cancelFn context.CancelFunc
-	registry map[string]Caller
+	callCh      chan errcatapi.Call
+	categorizer *categorizer.Categorizer
+	client      errcatapi.Client
+	ctx         context.Context
+	cancelFn    context.CancelFunc
+	faults      *faults.Injector
+	hooks       *Hooks
+	onCall      func(errcatapi.Call)
+
+	// The registry is guarded by the lock, since the callers of the
+	// config source are swapped in as it is reloaded. Reloads are
+	// serialized by the reload lock, so that the callers loaded by one
+	// are committed along with the changes of the same load.
+	lock           sync.RWMutex
+	onReload       func(ReloadEvent)
+	registry       map[string]Caller
+	reloadInterval time.Duration
+	reloadLock     sync.Mutex
+	source         ConfigSource
+	sourced        map[string]bool
 }
 
 type optionD func(d *Daemon)
 
 func NewD(opts ...optionD) *Daemon {
 	d := &Daemon{
-		callCh:   make(chan errcatapi.Call),
-		registry: make(map[string]Caller),
+		callCh:      make(chan errcatapi.Call),
+		categorizer: categorizer.New(),
+		registry:    make(map[string]Caller),
 	}
 	for _, opt := range opts {
 		opt(d)
@@ -41,6 +63,18 @@ func NewD(opts ...optionD) *Daemon {
 	return d
 }
 
+// WithCategorizer defines the categorizer used to classify the errors
+// of each call sent to the errcat server. By default, only the built-in
+// rules are applied.
+func WithCategorizer(c *categorizer.Categorizer) optionD {
+	return func(d *Daemon) {
+		if c == nil {
+			c = categorizer.New()
+		}
+		d.categorizer = c
+	}
+}
+
 // WithClient defines the client that should be used for sending metrics
 // to the errcat server. This allows finer control over the client than
 // WithServerAddr.
@@ -58,6 +92,38 @@ func WithEnvironment(env string) optionD {
 	}
 }
 
+// WithFaults injects the faults of the injector into the calls made by
+// the daemon, ahead of their callbacks, so that outages may be
+// rehearsed. The rules of the injector may be changed, or the injector
+// disabled, while the daemon is running. The reported calls name the
+// fault injected into them, so that they may be excluded from the
+// error rates of the dependency.
+func WithFaults(i *faults.Injector) optionD {
+	return func(d *Daemon) {
+		d.faults = i
+	}
+}
+
+// WithHooks defines the functions called as every call made by the
+// daemon progresses. This is useful for logging and measuring all calls
+// in the same way.
+func WithHooks(h Hooks) optionD {
+	return func(d *Daemon) {
+		d.hooks = &h
+	}
+}
+
+// WithOnCall defines a function that is called with each call once it
+// completes, before it is sent to the errcat server. The function is
+// called synchronously by the goroutine that made the call, which makes
+// it suitable for capturing calls in tests; see the errcattest package.
+// Calls are only sent when a client or server address is defined.
+func WithOnCall(fn func(errcatapi.Call)) optionD {
+	return func(d *Daemon) {
+		d.onCall = fn
+	}
+}
+
 // WithServerAddr will create a client for communicating to the errcat
 // server using the supplied server address.
 func WithServerAddr(addr url.URL) optionD {
@@ -81,6 +147,9 @@ func (d *Daemon) RegisterCaller(c Caller) (string, error) {
 		return "", nil
 	}
 
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
 	if _, ok := d.registry[c.key]; ok {
 		return c.key, fmt.Errorf(
 			"caller has already been registered with the dependency and name of %q and %q",
@@ -94,31 +163,107 @@ func (d *Daemon) RegisterCaller(c Caller) (string, error) {
 
 // Call executes the supplied function using the caller looked up with
 // the key.
-func (d *Daemon) Call(key string, cb CallFn) (err error) {
-	if d == nil {
+func (d *Daemon) Call(key string, cb CallFn) error {
+	return d.CallContext(context.Background(), key, func(context.Context) error {
 		return cb()
+	})
+}
+
+// CallContext executes the supplied function using the caller looked
+// up with the key. The context is propagated to the caller and on to
+// the callback.
+func (d *Daemon) CallContext(ctx context.Context, key string, cb CallContextFn) error {
+	return d.CallContextWithLabels(ctx, key, nil, cb)
+}
+
+// CallWithLabels executes the supplied function using the caller looked
+// up with the key, reporting the labels with the call. This allows the
+// calls to be sliced by attributes that vary from call to call, e.g.
+// the shard or endpoint being accessed.
+func (d *Daemon) CallWithLabels(key string, labels map[string]string, cb CallFn) error {
+	return d.CallContextWithLabels(context.Background(), key, labels, func(context.Context) error {
+		return cb()
+	})
+}
+
+// CallContextWithLabels is the context aware variant of CallWithLabels.
+// The labels take precedence over those added to the context with
+// WithLabels.
+func (d *Daemon) CallContextWithLabels(ctx context.Context, key string, labels map[string]string, cb CallContextFn) error {
+	if d == nil {
+		return cb(ctx)
 	}
 
+	return d.call(ctx, key, labels, func(ctx context.Context, caller Caller) error {
+		return caller.CallContext(ctx, cb)
+	})
+}
+
+// WithLabels returns a copy of the context carrying the labels, which
+// are reported with each call the daemon makes with it. This allows
+// labels to be reported with calls made by any of the daemon's methods
+// and functions, such as DoD, and to be added at the start of a request
+// rather than at each call. Labels added to a context that already
+// carries labels take precedence over them.
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	return context.WithValue(ctx, labelsKey{}, mergeLabels(labelsFrom(ctx), labels))
+}
+
+func labelsFrom(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
+	return labels
+}
+
+// call looks up the caller using the key and records the result of
+// running it with the supplied function.
+func (d *Daemon) call(ctx context.Context, key string, labels map[string]string, run func(context.Context, Caller) error) (err error) {
+	d.lock.RLock()
 	caller := d.registry[key]
+	d.lock.RUnlock()
 
 	call := errcatapi.Call{
 		Dependency: caller.dependency,
+		Labels:     mergeLabels(caller.labels, labelsFrom(ctx), labels),
 		Name:       caller.name,
 		StartedAt:  time.Now(),
 	}
+	call.Timeout, call.AttemptTimeout = caller.Timeouts()
+
+	rec := &recorder{}
+	ctx = withRecorder(ctx, rec)
+	if d.hooks != nil {
+		ctx = withHooks(ctx, d.hooks)
+	}
+	if d.faults != nil {
+		ctx = withFaults(ctx, d.faults)
+	}
 
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("%v", r)
+			err = &PanicError{
+				Dependency: caller.dependency,
+				Name:       caller.name,
+				Stack:      debug.Stack(),
+				Value:      r,
+			}
 		}
+		record := rec.finish(&call)
 		call.Error = err
+		call.Categories = d.categorize(caller, err)
+		call.WouldReject = d.categorizeRejections(caller, rec.rejections())
 		call.Duration = time.Now().Sub(call.StartedAt)
+		if !record {
+			return
+		}
+		if d.onCall != nil {
+			d.onCall(call)
+		}
 		if d.enabled() {
 			d.callCh <- call
 		}
 	}()
 
-	err = caller.Call(cb)
+	err = run(ctx, caller)
 	return
 }
 
@@ -129,6 +274,13 @@ func (d *Daemon) Start() {
 
 	d.ctx, d.cancelFn = context.WithCancel(context.Background())
 	go d.consumeCalls()
+
+	// The first load is completed before returning, so that the callers
+	// of the config source are available once started.
+	if d.source != nil {
+		d.Reload(d.ctx)
+		go d.watchConfig()
+	}
 }
 
 func (d *Daemon) Stop() {
@@ -173,6 +325,49 @@ func (d *Daemon) consumeCalls() {
 	}
 }
 
+func (d *Daemon) categorize(c Caller, err error) []string {
+	if c.categorizer != nil {
+		return c.categorizer.Categorize(err)
+	}
+	return d.categorizer.Categorize(err)
+}
+
+// categorizeRejections returns the categories of the rejections that
+// policies in shadow mode would have made, without duplicates.
+func (d *Daemon) categorizeRejections(c Caller, errs []error) []string {
+	var categories []string
+	seen := make(map[string]bool)
+	for _, err := range errs {
+		for _, category := range d.categorize(c, err) {
+			if !seen[category] {
+				seen[category] = true
+				categories = append(categories, category)
+			}
+		}
+	}
+	return categories
+}
+
+// mergeLabels combines the sets of labels, e.g. those of the caller with
+// those of the call, with later sets taking precedence.
+func mergeLabels(sets ...map[string]string) map[string]string {
+	n := 0
+	for _, set := range sets {
+		n += len(set)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string, n)
+	for _, set := range sets {
+		for k, v := range set {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
 func (d *Daemon) enabled() bool {
 	return d.client != nil || d.addr.Host != ""
 }
@@ -0,0 +1,426 @@
+package errcat_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go"
+	errcatapi "github.com/agschwender/errcat-go/api"
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/cache"
+	"github.com/agschwender/errcat-go/categorizer"
+	"github.com/agschwender/errcat-go/errcattest"
+	"github.com/agschwender/errcat-go/fallback"
+	"github.com/agschwender/errcat-go/faults"
+	"github.com/agschwender/errcat-go/retrier"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+type fakeClient struct {
+	requests chan errcatapi.RecordCallsRequest
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{requests: make(chan errcatapi.RecordCallsRequest, 1)}
+}
+
+func (c *fakeClient) Close() error { return nil }
+
+func (c *fakeClient) RecordCalls(_ context.Context, req errcatapi.RecordCallsRequest) error {
+	c.requests <- req
+	return nil
+}
+
+// recordCalls runs the supplied function against a started daemon and
+// returns the calls that were sent once the daemon is stopped.
+func recordCalls(t *testing.T, d *errcat.Daemon, client *fakeClient, fn func()) []errcatapi.Call {
+	t.Helper()
+
+	d.Start()
+	fn()
+	d.Stop()
+
+	req := <-client.requests
+	return req.Calls
+}
+
+func TestDaemonCategories(t *testing.T) {
+	client := newFakeClient()
+	d := errcat.NewD(errcat.WithClient(client), errcat.WithEnvironment("test"))
+
+	key, err := d.RegisterCaller(
+		errcat.New("mysql", "users.GetUser").
+			WithBreaker(breaker.New(breaker.WithMaxFailures(1))),
+	)
+	require.NoError(t, err)
+
+	errDeadlock := fmt.Errorf("deadlock")
+	customKey, err := d.RegisterCaller(
+		errcat.New("mysql", "users.UpdateUser").
+			WithCategorizer(categorizer.New(
+				categorizer.WithRules(categorizer.Is(errDeadlock, "deadlock")),
+			)),
+	)
+	require.NoError(t, err)
+
+	calls := recordCalls(t, d, client, func() {
+		d.Call(key, func() error { return nil })
+		d.Call(key, func() error { return context.DeadlineExceeded })
+		d.Call(key, func() error { return nil })
+		d.Call(customKey, func() error { return errDeadlock })
+	})
+
+	require.Len(t, calls, 4)
+	assert.Nil(t, calls[0].Categories)
+	assert.Equal(t, []string{categorizer.Timeout}, calls[1].Categories)
+	assert.Equal(t, []string{categorizer.BreakerOpen}, calls[2].Categories)
+	assert.Equal(t, []string{"deadlock"}, calls[3].Categories)
+	assert.Equal(t, "users.UpdateUser", calls[3].Name)
+}
+
+func TestDaemonAttempts(t *testing.T) {
+	client := newFakeClient()
+	d := errcat.NewD(errcat.WithClient(client))
+
+	retryKey, err := d.RegisterCaller(
+		errcat.New("mysql", "users.GetUser").
+			WithRetrier(retrier.New(retrier.WithMaxAttempts(3))),
+	)
+	require.NoError(t, err)
+
+	hedgeKey, err := d.RegisterCaller(
+		errcat.New("google", "clients.Google.Search").
+			WithHedging(time.Duration(10)*time.Millisecond, 1),
+	)
+	require.NoError(t, err)
+
+	calls := recordCalls(t, d, client, func() {
+		counts := 0
+		d.Call(retryKey, func() error {
+			counts++
+			if counts < 3 {
+				return fmt.Errorf("oops")
+			}
+			return nil
+		})
+
+		var lock sync.Mutex
+		hedges := 0
+		d.CallContext(context.Background(), hedgeKey, func(ctx context.Context) error {
+			lock.Lock()
+			hedges++
+			hedge := hedges
+			lock.Unlock()
+
+			if hedge == 1 {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		})
+	})
+
+	require.Len(t, calls, 2)
+
+	// Each retry is recorded as an attempt
+	require.Len(t, calls[0].Attempts, 3)
+	assert.Equal(t, "oops", calls[0].Attempts[0].Error.Error())
+	assert.Equal(t, "oops", calls[0].Attempts[1].Error.Error())
+	assert.NoError(t, calls[0].Attempts[2].Error)
+	assert.Equal(t, uint(0), calls[0].Hedge)
+
+	// The hedge that succeeded is recorded. The cancelled attempt may
+	// complete after the call is recorded, in which case it is dropped.
+	assert.Equal(t, uint(1), calls[1].Hedge)
+	require.NotEmpty(t, calls[1].Attempts)
+	assert.Equal(t, uint(1), calls[1].Attempts[0].Hedge)
+	assert.NoError(t, calls[1].Attempts[0].Error)
+}
+
+func TestDaemonLabels(t *testing.T) {
+	client := newFakeClient()
+	d := errcat.NewD(errcat.WithClient(client))
+
+	key, err := d.RegisterCaller(
+		errcat.New("mysql", "users.GetUser").
+			WithLabels(map[string]string{"region": "us-east-1", "table": "users"}),
+	)
+	require.NoError(t, err)
+
+	otherKey, err := d.RegisterCaller(errcat.New("mysql", "users.ListUsers"))
+	require.NoError(t, err)
+
+	calls := recordCalls(t, d, client, func() {
+		d.Call(key, func() error { return nil })
+		d.CallWithLabels(key, map[string]string{"shard": "3", "table": "users_archive"}, func() error {
+			return nil
+		})
+		d.CallContextWithLabels(context.Background(), otherKey, map[string]string{"shard": "4"}, func(context.Context) error {
+			return nil
+		})
+		d.Call(otherKey, func() error { return nil })
+
+		ctx := errcat.WithLabels(context.Background(), map[string]string{"shard": "5", "table": "users_archive"})
+		errcat.DoD(ctx, d, key, func(context.Context) (string, error) { return "Alice", nil })
+		d.CallContextWithLabels(errcat.WithLabels(ctx, map[string]string{"endpoint": "get"}), otherKey, map[string]string{"shard": "6"}, func(context.Context) error {
+			return nil
+		})
+	})
+
+	require.Len(t, calls, 6)
+	assert.Equal(t, map[string]string{"region": "us-east-1", "table": "users"}, calls[0].Labels)
+	assert.Equal(t, map[string]string{"region": "us-east-1", "shard": "3", "table": "users_archive"}, calls[1].Labels)
+	assert.Equal(t, map[string]string{"shard": "4"}, calls[2].Labels)
+	assert.Nil(t, calls[3].Labels)
+
+	// Confirm the labels of the context are reported with typed calls and
+	// are overridden by those of the call
+	assert.Equal(t, map[string]string{"region": "us-east-1", "shard": "5", "table": "users_archive"}, calls[4].Labels)
+	assert.Equal(t, map[string]string{"endpoint": "get", "shard": "6", "table": "users_archive"}, calls[5].Labels)
+}
+
+func TestDaemonFallbackTier(t *testing.T) {
+	client := newFakeClient()
+	d := errcat.NewD(errcat.WithClient(client))
+
+	key, err := d.RegisterCaller(
+		errcat.New("mysql", "users.GetUser").
+			WithFallback(fallback.Chain(
+				fallback.New(func() error { return fmt.Errorf("replica down") }, fallback.WithName("replica")),
+				fallback.New(nil, fallback.WithName("default")),
+			)),
+	)
+	require.NoError(t, err)
+
+	cache := fallback.NewCache[string](0, 0)
+	calls := recordCalls(t, d, client, func() {
+		d.Call(key, func() error { return nil })
+		d.Call(key, func() error { return fmt.Errorf("oops") })
+		errcat.DoDWithCache(context.Background(), d, key, cache, "alice", func(context.Context) (string, error) {
+			return "Alice", nil
+		})
+		errcat.DoDWithCache(context.Background(), d, key, cache, "alice", func(context.Context) (string, error) {
+			return "", fmt.Errorf("oops")
+		})
+		errcat.DoDWithFallback(context.Background(), d, key, func(context.Context) (string, error) {
+			return "", fmt.Errorf("oops")
+		}, fallback.Value("unknown"))
+	})
+
+	require.Len(t, calls, 5)
+	assert.Equal(t, "", calls[0].Fallback)
+	assert.Equal(t, "default", calls[1].Fallback)
+	assert.NoError(t, calls[1].Error)
+	assert.Equal(t, "", calls[2].Fallback)
+	assert.Equal(t, "cache", calls[3].Fallback)
+	assert.Equal(t, "1", calls[4].Fallback)
+}
+
+func TestDaemonTimeouts(t *testing.T) {
+	client := newFakeClient()
+	d := errcat.NewD(errcat.WithClient(client))
+
+	adaptive := timer.NewAdaptive(time.Second, timer.WithFloor(time.Duration(50)*time.Millisecond), timer.WithWarmup(1))
+	key, err := d.RegisterCaller(
+		errcat.New("mysql", "users.GetUser").
+			WithTimer(adaptive).
+			WithAttemptTimeout(time.Duration(100) * time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	calls := recordCalls(t, d, client, func() {
+		d.Call(key, func() error { return nil })
+		d.Call(key, func() error { return nil })
+	})
+
+	// The adaptive timeout in effect when each call is made is reported
+	require.Len(t, calls, 2)
+	assert.Equal(t, time.Second, calls[0].Timeout)
+	assert.Equal(t, time.Duration(50)*time.Millisecond, calls[1].Timeout)
+	assert.Equal(t, time.Duration(100)*time.Millisecond, calls[1].AttemptTimeout)
+}
+
+func TestDaemonCoalescing(t *testing.T) {
+	client := newFakeClient()
+	d := errcat.NewD(errcat.WithClient(client))
+
+	var keyed int32
+	key, err := d.RegisterCaller(
+		errcat.New("mysql", "users.GetName").
+			WithCoalescing(coalesceByKey(&keyed)),
+	)
+	require.NoError(t, err)
+	ctx := context.WithValue(context.Background(), coalesceKey{}, "alice")
+
+	calls := recordCalls(t, d, client, func() {
+		release := make(chan bool)
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errcat.DoD(ctx, d, key, func(context.Context) (string, error) {
+					<-release
+					return "Alice", nil
+				})
+			}()
+		}
+		awaitCoalesced(t, &keyed, 3)
+		close(release)
+		wg.Wait()
+	})
+
+	// Confirm a single call is recorded along with the calls that
+	// waited on it
+	require.Len(t, calls, 1)
+	assert.Equal(t, uint(2), calls[0].Coalesced)
+	assert.Len(t, calls[0].Attempts, 1)
+
+	calls = recordCalls(t, d, client, func() {
+		release := make(chan bool)
+		done := make(chan bool)
+		go func() {
+			defer close(done)
+			errcat.DoD(ctx, d, key, func(context.Context) (string, error) {
+				<-release
+				return "Alice", nil
+			})
+		}()
+		awaitCoalesced(t, &keyed, 4)
+
+		waitCtx, cancel := context.WithTimeout(ctx, time.Duration(10)*time.Millisecond)
+		defer cancel()
+		_, err := errcat.DoD(waitCtx, d, key, func(context.Context) (string, error) {
+			return "Alice", nil
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		close(release)
+		<-done
+	})
+
+	// Confirm a call that gave up waiting is recorded on its own
+	require.Len(t, calls, 2)
+	assert.ErrorIs(t, calls[0].Error, context.DeadlineExceeded)
+	assert.Len(t, calls[0].Attempts, 0)
+	assert.Equal(t, uint(0), calls[1].Coalesced)
+}
+
+func TestDaemonCached(t *testing.T) {
+	client := newFakeClient()
+	d := errcat.NewD(errcat.WithClient(client))
+
+	key, err := d.RegisterCaller(
+		errcat.New("mysql", "users.GetName").
+			WithCache(cache.New(time.Minute, userKeyFn)),
+	)
+	require.NoError(t, err)
+	ctx := context.WithValue(context.Background(), userKey{}, "alice")
+
+	calls := recordCalls(t, d, client, func() {
+		for i := 0; i < 2; i++ {
+			errcat.DoD(ctx, d, key, func(context.Context) (string, error) {
+				return "Alice", nil
+			})
+		}
+	})
+
+	// Confirm the call served by the cache is recorded as such
+	require.Len(t, calls, 2)
+	assert.False(t, calls[0].Cached)
+	assert.Len(t, calls[0].Attempts, 1)
+	assert.True(t, calls[1].Cached)
+	assert.Len(t, calls[1].Attempts, 0)
+}
+
+func TestDaemonFaults(t *testing.T) {
+	client := newFakeClient()
+	injector := faults.New()
+	d := errcat.NewD(errcat.WithClient(client), errcat.WithFaults(injector))
+
+	key, err := d.RegisterCaller(
+		errcat.New("mysql", "users.GetUser").
+			WithRetrier(retrier.New(retrier.WithMaxAttempts(2))),
+	)
+	require.NoError(t, err)
+
+	errOutage := fmt.Errorf("connection refused")
+	require.NoError(t, injector.Add(
+		faults.Rule{Name: "outage", Key: "mysql:*", Error: errOutage},
+		faults.Rule{Name: "panic", Key: "google:*", Panic: true},
+	))
+
+	calls := recordCalls(t, d, client, func() {
+		// Confirm the fault is injected into each attempt, in place of
+		// the callback
+		called := false
+		err := d.Call(key, func() error {
+			called = true
+			return nil
+		})
+		assert.ErrorIs(t, err, errOutage)
+		assert.False(t, called)
+
+		// Confirm no fault is injected once removed
+		injector.Remove("outage")
+		require.NoError(t, d.Call(key, func() error { return nil }))
+	})
+
+	// Confirm the injected fault is reported with the call
+	require.Len(t, calls, 2)
+	assert.Equal(t, "outage", calls[0].Fault)
+	assert.Len(t, calls[0].Attempts, 2)
+	assert.Equal(t, "", calls[1].Fault)
+}
+
+func TestDaemonFaultsPanic(t *testing.T) {
+	rec := errcattest.NewRecorder()
+	injector := faults.New()
+	d := errcat.NewD(errcat.WithOnCall(rec.Record), errcat.WithFaults(injector))
+
+	key, err := d.RegisterCaller(errcat.New("google", "clients.Google.Search"))
+	require.NoError(t, err)
+	require.NoError(t, injector.Add(faults.Rule{Name: "crash", Key: key, Panic: true}))
+
+	err = d.Call(key, func() error { return nil })
+	var panicErr *errcat.PanicError
+	require.ErrorAs(t, err, &panicErr)
+
+	calls := errcattest.AssertCalls(t, rec, key, 1)
+	assert.Equal(t, "crash", calls[0].Fault)
+}
+
+func TestDaemonShadowMode(t *testing.T) {
+	d, rec := errcattest.NewDaemon(
+		errcat.New("mysql", "users.GetUser").
+			WithBreaker(breaker.New(
+				breaker.WithMaxFailures(uint(1)),
+				breaker.WithShadowMode(true),
+			)),
+	)
+	key := "mysql:users.GetUser"
+
+	errOops := fmt.Errorf("oops")
+	assert.Equal(t, errOops, d.Call(key, func() error { return errOops }))
+
+	// Confirm the open breaker lets the call through
+	called := false
+	err := d.Call(key, func() error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, called)
+
+	// Confirm the rejection the breaker would have made is recorded
+	calls := errcattest.AssertCalls(t, rec, key, 2)
+	assert.Empty(t, calls[0].WouldReject)
+	assert.Equal(t, []string{categorizer.BreakerOpen}, calls[1].WouldReject)
+	errcattest.AssertCategories(t, calls[1])
+}
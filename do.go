@@ -0,0 +1,171 @@
+package errcat
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/agschwender/errcat-go/cache"
+	"github.com/agschwender/errcat-go/fallback"
+)
+
+// DoFn is the typed variant of CallContextFn, returning the value
+// produced by the dependency along with any error.
+type DoFn[T any] func(ctx context.Context) (T, error)
+
+// Do executes the callback using the caller and returns the value it
+// produced. When the call fails, the zero value of T is returned along
+// with the error, or with the result of the caller's fallback if one
+// applies.
+func Do[T any](ctx context.Context, c Caller, fn DoFn[T]) (T, error) {
+	return do(ctx, c, fn, nil)
+}
+
+// DoWithFallback executes the callback using the caller and returns the
+// value it produced. When the call fails, the typed fallback is used to
+// supply a substitute value. The typed fallback takes the place of the
+// caller's own fallback.
+func DoWithFallback[T any](ctx context.Context, c Caller, fn DoFn[T], f *fallback.Typed[T]) (T, error) {
+	return do(ctx, c, fn, f)
+}
+
+// DoD executes the callback using the caller looked up with the key and
+// returns the value it produced. It is the typed variant of
+// Daemon.CallContext. Labels are reported with the call by adding them
+// to the context with WithLabels.
+func DoD[T any](ctx context.Context, d *Daemon, key string, fn DoFn[T]) (T, error) {
+	return doD(ctx, d, key, fn, nil)
+}
+
+// DoDWithFallback executes the callback using the caller looked up with
+// the key and returns the value it produced, using the typed fallback
+// to supply a substitute value when the call fails.
+func DoDWithFallback[T any](ctx context.Context, d *Daemon, key string, fn DoFn[T], f *fallback.Typed[T]) (T, error) {
+	return doD(ctx, d, key, fn, f)
+}
+
+func doD[T any](ctx context.Context, d *Daemon, key string, fn DoFn[T], f *fallback.Typed[T]) (T, error) {
+	if d == nil {
+		return do(ctx, Caller{}, fn, f)
+	}
+
+	var v T
+	err := d.call(ctx, key, nil, func(ctx context.Context, caller Caller) (err error) {
+		v, err = do(ctx, caller, fn, f)
+		return err
+	})
+	return v, err
+}
+
+func do[T any](ctx context.Context, c Caller, fn DoFn[T], f *fallback.Typed[T]) (v T, err error) {
+	o := c.observe(ctx)
+	defer func() {
+		o.complete(err)
+	}()
+
+	v, err = runDo(ctx, c, o, fn)
+	if err == nil {
+		return v, nil
+	}
+	return fallbackDo(ctx, c, o, err, f)
+}
+
+// DoWithCache executes the callback using the caller and stores the
+// value it produced in the cache under the cache key. When the call
+// fails, the last value stored under the cache key is returned in its
+// place, with stale set to indicate it is not the result of this call.
+// When no value is available, the caller's fallback applies as usual.
+func DoWithCache[T any](ctx context.Context, c Caller, cache *fallback.Cache[T], cacheKey string, fn DoFn[T]) (v T, stale bool, err error) {
+	return doWithCache(ctx, c, cache, cacheKey, fn)
+}
+
+// DoDWithCache is the variant of DoWithCache that uses the caller looked
+// up with the key.
+func DoDWithCache[T any](ctx context.Context, d *Daemon, key string, cache *fallback.Cache[T], cacheKey string, fn DoFn[T]) (v T, stale bool, err error) {
+	if d == nil {
+		return doWithCache(ctx, Caller{}, cache, cacheKey, fn)
+	}
+
+	err = d.call(ctx, key, nil, func(ctx context.Context, caller Caller) (err error) {
+		v, stale, err = doWithCache(ctx, caller, cache, cacheKey, fn)
+		return err
+	})
+	return v, stale, err
+}
+
+func doWithCache[T any](ctx context.Context, c Caller, cache *fallback.Cache[T], cacheKey string, fn DoFn[T]) (v T, stale bool, err error) {
+	o := c.observe(ctx)
+	defer func() {
+		o.complete(err)
+	}()
+
+	v, err = runDo(ctx, c, o, fn)
+	if err == nil {
+		cache.Store(cacheKey, v)
+		return v, false, nil
+	}
+
+	if cache.UseFallback(err) {
+		if cached, _, ok := cache.Load(cacheKey); ok {
+			o.fallback(err)
+			recorderFrom(ctx).fellBack(cache.Name())
+			return cached, true, nil
+		}
+	}
+
+	v, err = fallbackDo[T](ctx, c, o, err, nil)
+	return v, false, err
+}
+
+// runDo runs the callback through the policies of the caller, returning
+// the value it produced or the cached value. When the caller coalesces
+// calls, the value is shared with the calls that waited on it.
+func runDo[T any](ctx context.Context, c Caller, o *observer, fn DoFn[T]) (T, error) {
+	v, err := c.coalescer.do(ctx, reflect.TypeOf((*T)(nil)).Elem(), func(ctx context.Context) (interface{}, error) {
+		r := cache.ResultOf[T]()
+		err := c.runPolicies(ctx, o, r, func(ctx context.Context) error {
+			// Only successful values are kept, so that an attempt that
+			// fails late, e.g. a cancelled hedge, does not replace the
+			// value of the attempt that succeeded.
+			v, err := fn(ctx)
+			if err == nil {
+				r.Set(v)
+			}
+			return err
+		})
+		return r.Value(), err
+	})
+
+	// The value is only missing when the call failed.
+	t, _ := v.(T)
+	return t, err
+}
+
+// fallbackDo applies the typed fallback, or the caller's fallback when
+// there is none, to the error of a failed call.
+func fallbackDo[T any](ctx context.Context, c Caller, o *observer, err error, f *fallback.Typed[T]) (v T, _ error) {
+	if f != nil {
+		if f.UseFallback(err) {
+			o.fallback(err)
+			err = c.useFallback(ctx, func(ctx context.Context, err error) (tier string, _ error) {
+				v, tier, err = f.Use(ctx, err)
+				return tier, err
+			}, err)
+			return v, err
+		}
+		return v, err
+	}
+	if c.fallback.UseFallback(err) {
+		o.fallback(err)
+		return v, c.useFallback(ctx, c.fallback.Use, err)
+	}
+	return v, err
+}
+
+// FallbackDo creates a typed fallback function that executes the
+// callback using the caller. It is the typed variant of
+// Caller.FallbackFn.
+func FallbackDo[T any](c Caller, fn DoFn[T]) fallback.TypedFallbackContextFn[T] {
+	return func(ctx context.Context, _ error) (T, error) {
+		return Do(withRecorder(ctx, nil), c, fn)
+	}
+}
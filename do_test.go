@@ -0,0 +1,288 @@
+package errcat_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go"
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/fallback"
+	"github.com/agschwender/errcat-go/retrier"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+func TestDo(t *testing.T) {
+	c := errcat.New("mysql", "users.GetName").
+		WithRetrier(retrier.New(retrier.WithMaxAttempts(3)))
+
+	// Confirm the value from the successful attempt is returned
+	counts := 0
+	v, err := errcat.Do(context.Background(), c, func(ctx context.Context) (string, error) {
+		counts++
+		if counts < 2 {
+			return "partial", fmt.Errorf("oops")
+		}
+		return "alice", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", v)
+	assert.Equal(t, 2, counts)
+
+	// Confirm the zero value is returned on failure
+	v, err = errcat.Do(context.Background(), c, func(ctx context.Context) (string, error) {
+		return "partial", fmt.Errorf("oops")
+	})
+	require.Error(t, err)
+	assert.Equal(t, "", v)
+
+	// Confirm the caller's fallback is used
+	c = c.WithFallback(fallback.New(nil))
+	v, err = errcat.Do(context.Background(), c, func(ctx context.Context) (string, error) {
+		return "partial", fmt.Errorf("oops")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "", v)
+}
+
+func TestDoWithTimeout(t *testing.T) {
+	c := errcat.New("mysql", "users.GetName").
+		WithTimeout(time.Duration(10) * time.Millisecond)
+
+	done := make(chan bool)
+	v, err := errcat.Do(context.Background(), c, func(ctx context.Context) (string, error) {
+		defer close(done)
+		<-ctx.Done()
+		return "late", nil
+	})
+	<-done
+	assert.ErrorIs(t, err, timer.ErrTimeout)
+	assert.Equal(t, "", v)
+}
+
+func TestDoWithHedging(t *testing.T) {
+	c := errcat.New("google", "clients.Google.Search").
+		WithHedging(time.Millisecond, 1)
+
+	// Confirm the value of the winning hedge is returned, even though
+	// the cancelled attempt completes afterwards
+	for i := 0; i < 100; i++ {
+		var counts int32
+		v, err := errcat.Do(context.Background(), c, func(ctx context.Context) (string, error) {
+			if atomic.AddInt32(&counts, 1) == 1 {
+				<-ctx.Done()
+				return "partial", ctx.Err()
+			}
+			return "alice", nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "alice", v)
+	}
+}
+
+func TestDoWithFallback(t *testing.T) {
+	callerFallbacks := 0
+	c := errcat.New("mysql", "users.GetName").
+		WithFallback(fallback.New(func() error {
+			callerFallbacks++
+			return nil
+		}))
+
+	v, err := errcat.DoWithFallback(context.Background(), c, func(ctx context.Context) (string, error) {
+		return "", fmt.Errorf("oops")
+	}, fallback.Value("unknown"))
+	require.NoError(t, err)
+	assert.Equal(t, "unknown", v)
+	assert.Equal(t, 0, callerFallbacks)
+
+	// Confirm the typed fallback's predicate is respected
+	v, err = errcat.DoWithFallback(context.Background(), c, func(ctx context.Context) (string, error) {
+		return "", fmt.Errorf("no fallback")
+	}, fallback.Value("unknown", fallback.WithUseFallback(func(err error) bool {
+		return err != nil && err.Error() != "no fallback"
+	})))
+	require.Error(t, err)
+	assert.Equal(t, "", v)
+	assert.Equal(t, 0, callerFallbacks)
+}
+
+func TestDoWithCoalescing(t *testing.T) {
+	var keyed int32
+	c := errcat.New("mysql", "users.GetName").
+		WithCoalescing(coalesceByKey(&keyed))
+	ctx := context.WithValue(context.Background(), coalesceKey{}, "alice")
+
+	// Confirm the value produced by the call is shared with the calls
+	// that waited on it
+	var counts int32
+	release := make(chan bool)
+	results := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			v, err := errcat.Do(ctx, c, func(ctx context.Context) (string, error) {
+				atomic.AddInt32(&counts, 1)
+				<-release
+				return "Alice", nil
+			})
+			assert.NoError(t, err)
+			results <- v
+		}()
+	}
+	awaitCoalesced(t, &keyed, 3)
+	close(release)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "Alice", <-results)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&counts))
+}
+
+func TestDoWithCoalescingMixedTypes(t *testing.T) {
+	var keyed int32
+	c := errcat.New("mysql", "users.GetName").
+		WithCoalescing(coalesceByKey(&keyed))
+	ctx := context.WithValue(context.Background(), coalesceKey{}, "alice")
+
+	// Confirm calls that expect different types of value do not share a
+	// result
+	var counts int32
+	release := make(chan bool)
+	done := make(chan error)
+	go func() {
+		done <- c.CallContext(ctx, func(context.Context) error {
+			atomic.AddInt32(&counts, 1)
+			<-release
+			return nil
+		})
+	}()
+	go func() {
+		_, err := errcat.Do(ctx, c, func(context.Context) (int, error) {
+			atomic.AddInt32(&counts, 1)
+			<-release
+			return 42, nil
+		})
+		done <- err
+	}()
+	awaitCoalesced(t, &keyed, 2)
+
+	v, err := errcat.Do(ctx, c, func(context.Context) (string, error) {
+		atomic.AddInt32(&counts, 1)
+		return "Alice", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", v)
+
+	close(release)
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&counts))
+}
+
+func TestDoD(t *testing.T) {
+	// Confirm a nil daemon runs the callback directly
+	var d *errcat.Daemon
+	v, err := errcat.DoD(context.Background(), d, "mysql:users.GetName", func(ctx context.Context) (int, error) {
+		return 42, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 42, v)
+
+	d = errcat.NewD()
+	key, err := d.RegisterCaller(
+		errcat.New("mysql", "users.GetName").
+			WithRetrier(retrier.New(retrier.WithMaxAttempts(2))),
+	)
+	require.NoError(t, err)
+
+	counts := 0
+	v, err = errcat.DoD(context.Background(), d, key, func(ctx context.Context) (int, error) {
+		counts++
+		if counts == 1 {
+			return 0, fmt.Errorf("oops")
+		}
+		return 42, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 42, v)
+	assert.Equal(t, 2, counts)
+
+	v, err = errcat.DoDWithFallback(context.Background(), d, key, func(ctx context.Context) (int, error) {
+		return 0, fmt.Errorf("oops")
+	}, fallback.Value(-1))
+	require.NoError(t, err)
+	assert.Equal(t, -1, v)
+}
+
+func TestDoWithCache(t *testing.T) {
+	callerFallbacks := 0
+	c := errcat.New("mysql", "products.GetProduct").
+		WithBreaker(breaker.New(breaker.WithMaxFailures(2))).
+		WithFallback(fallback.New(func() error {
+			callerFallbacks++
+			return nil
+		}))
+	cache := fallback.NewCache[string](10, time.Minute)
+
+	// Confirm the caller's fallback applies until a value is stored
+	v, stale, err := errcat.DoWithCache(context.Background(), c, cache, "1", func(ctx context.Context) (string, error) {
+		return "", fmt.Errorf("oops")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "", v)
+	assert.False(t, stale)
+	assert.Equal(t, 1, callerFallbacks)
+
+	v, stale, err = errcat.DoWithCache(context.Background(), c, cache, "1", func(ctx context.Context) (string, error) {
+		return "chair", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "chair", v)
+	assert.False(t, stale)
+
+	// Confirm the stored value is served when the call fails and when
+	// the breaker is open
+	for i := 0; i < 3; i++ {
+		v, stale, err = errcat.DoWithCache(context.Background(), c, cache, "1", func(ctx context.Context) (string, error) {
+			return "", fmt.Errorf("oops")
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "chair", v)
+		assert.True(t, stale)
+	}
+	assert.Equal(t, 1, callerFallbacks)
+
+	// Confirm values are stored by key, with the breaker now open
+	v, stale, err = errcat.DoWithCache(context.Background(), c, cache, "2", func(ctx context.Context) (string, error) {
+		return "table", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "", v)
+	assert.False(t, stale)
+	assert.Equal(t, 2, callerFallbacks)
+}
+
+func TestDoDWithCache(t *testing.T) {
+	d := errcat.NewD()
+	key, err := d.RegisterCaller(errcat.New("mysql", "products.GetProduct"))
+	require.NoError(t, err)
+
+	cache := fallback.NewCache[string](10, time.Minute)
+	v, stale, err := errcat.DoDWithCache(context.Background(), d, key, cache, "1", func(ctx context.Context) (string, error) {
+		return "chair", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "chair", v)
+	assert.False(t, stale)
+
+	v, stale, err = errcat.DoDWithCache(context.Background(), d, key, cache, "1", func(ctx context.Context) (string, error) {
+		return "", fmt.Errorf("oops")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "chair", v)
+	assert.True(t, stale)
+}
@@ -0,0 +1,75 @@
+package errcattest
+
+import (
+	"sort"
+	"testing"
+
+	errcatapi "github.com/agschwender/errcat-go/api"
+)
+
+// AssertCalls checks that the recorder has recorded the number of calls
+// for the caller with the key, returning the calls.
+func AssertCalls(t testing.TB, r *Recorder, key string, n int) []errcatapi.Call {
+	t.Helper()
+
+	calls := r.CallsFor(key)
+	if len(calls) != n {
+		t.Errorf("expected %d calls for %q, got %d", n, key, len(calls))
+	}
+	return calls
+}
+
+// AssertCategories checks that the error of the call was classified in
+// exactly the categories, regardless of their order. Without any
+// categories, it checks the call was not classified at all.
+func AssertCategories(t testing.TB, call errcatapi.Call, categories ...string) bool {
+	t.Helper()
+
+	expected, actual := sorted(categories), sorted(call.Categories)
+	if len(expected) != len(actual) {
+		t.Errorf("expected %s call to have categories %v, got %v", Key(call), expected, actual)
+		return false
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Errorf("expected %s call to have categories %v, got %v", Key(call), expected, actual)
+			return false
+		}
+	}
+	return true
+}
+
+// AssertAttempts checks that the number of attempts made by the call,
+// including any hedged attempts, matches.
+func AssertAttempts(t testing.TB, call errcatapi.Call, n int) bool {
+	t.Helper()
+
+	if len(call.Attempts) != n {
+		t.Errorf("expected %s call to make %d attempts, got %d", Key(call), n, len(call.Attempts))
+		return false
+	}
+	return true
+}
+
+// AssertRetries checks that the number of attempts made by the call
+// after the first matches.
+func AssertRetries(t testing.TB, call errcatapi.Call, n int) bool {
+	t.Helper()
+
+	retries := len(call.Attempts) - 1
+	if retries < 0 {
+		retries = 0
+	}
+	if retries != n {
+		t.Errorf("expected %s call to retry %d times, got %d", Key(call), n, retries)
+		return false
+	}
+	return true
+}
+
+func sorted(values []string) []string {
+	s := make([]string, len(values))
+	copy(s, values)
+	sort.Strings(s)
+	return s
+}
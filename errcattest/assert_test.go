@@ -0,0 +1,69 @@
+package errcattest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	errcatapi "github.com/agschwender/errcat-go/api"
+	"github.com/agschwender/errcat-go/errcattest"
+)
+
+// fakeT records the failures reported by the assertion helpers.
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertCalls(t *testing.T) {
+	rec := errcattest.NewRecorder()
+	rec.Record(errcatapi.Call{Dependency: "mysql", Name: "users.GetUser"})
+	rec.Record(errcatapi.Call{Dependency: "mysql", Name: "users.ListUsers"})
+
+	ft := &fakeT{}
+	calls := errcattest.AssertCalls(ft, rec, "mysql:users.GetUser", 1)
+	assert.Len(t, calls, 1)
+	assert.Empty(t, ft.errors)
+
+	errcattest.AssertCalls(ft, rec, "mysql:users.GetUser", 2)
+	assert.Equal(t, []string{`expected 2 calls for "mysql:users.GetUser", got 1`}, ft.errors)
+}
+
+func TestAssertCategories(t *testing.T) {
+	call := errcatapi.Call{Categories: []string{"timeout", "network"}, Dependency: "mysql", Name: "users.GetUser"}
+
+	ft := &fakeT{}
+	assert.True(t, errcattest.AssertCategories(ft, call, "network", "timeout"))
+	assert.Empty(t, ft.errors)
+
+	assert.False(t, errcattest.AssertCategories(ft, call, "timeout"))
+	assert.False(t, errcattest.AssertCategories(ft, call, "timeout", "dns"))
+	assert.Equal(t, []string{
+		"expected mysql:users.GetUser call to have categories [timeout], got [network timeout]",
+		"expected mysql:users.GetUser call to have categories [dns timeout], got [network timeout]",
+	}, ft.errors)
+}
+
+func TestAssertAttempts(t *testing.T) {
+	call := errcatapi.Call{Attempts: make([]errcatapi.Attempt, 3), Dependency: "mysql", Name: "users.GetUser"}
+
+	ft := &fakeT{}
+	assert.True(t, errcattest.AssertAttempts(ft, call, 3))
+	assert.True(t, errcattest.AssertRetries(ft, call, 2))
+	assert.True(t, errcattest.AssertRetries(ft, errcatapi.Call{}, 0))
+	assert.Empty(t, ft.errors)
+
+	assert.False(t, errcattest.AssertAttempts(ft, call, 1))
+	assert.False(t, errcattest.AssertRetries(ft, call, 1))
+	assert.Equal(t, []string{
+		"expected mysql:users.GetUser call to make 1 attempts, got 3",
+		"expected mysql:users.GetUser call to retry 1 times, got 2",
+	}, ft.errors)
+}
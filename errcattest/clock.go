@@ -0,0 +1,45 @@
+package errcattest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a fake clock whose time only changes when it is advanced. Its
+// Now method may be supplied to the WithNow option of the breaker, rate
+// limiter, retrier and other policies, so that tests can, for example,
+// move past the timeout of an open breaker without waiting for it. It
+// is safe for concurrent use.
+type Clock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+// NewClock creates a new Clock set to the time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by the duration.
+func (c *Clock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to the time.
+func (c *Clock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = now
+}
@@ -0,0 +1,119 @@
+// Package errcattest provides utilities for testing code that makes
+// calls using errcat. A Recorder captures every call made by a daemon
+// as it completes, so that tests can assert how their calls were made
+// without starting the daemon or waiting for it to send them:
+//
+//	clock := errcattest.NewClock(time.Now())
+//	d, rec := errcattest.NewDaemon(
+//		errcat.New("mysql", "users.GetUser").
+//			WithBreaker(breaker.New(breaker.WithNow(clock.Now))).
+//			WithRetrier(retrier.New(retrier.WithMaxAttempts(3))),
+//	)
+//
+//	users.GetUser(ctx, d, "alice")
+//
+//	calls := errcattest.AssertCalls(t, rec, "mysql:users.GetUser", 1)
+//	errcattest.AssertRetries(t, calls[0], 2)
+//	errcattest.AssertCategories(t, calls[0], categorizer.Timeout)
+package errcattest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/agschwender/errcat-go"
+	errcatapi "github.com/agschwender/errcat-go/api"
+)
+
+// Recorder records calls, either as they complete, using Record with
+// errcat.WithOnCall, or as they are sent, by acting as the client of a
+// daemon. It is safe for concurrent use.
+type Recorder struct {
+	lock  sync.Mutex
+	calls []errcatapi.Call
+}
+
+var _ errcatapi.Client = (*Recorder)(nil)
+
+// NewRecorder creates a new Recorder without any calls.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// NewDaemon creates a daemon with the callers registered that records
+// each call with the returned recorder as it completes. The daemon does
+// not need to be started. Use errcat.WithOnCall with Record to record
+// the calls of a daemon that requires other options.
+func NewDaemon(callers ...errcat.Caller) (*errcat.Daemon, *Recorder) {
+	r := NewRecorder()
+	d := errcat.NewD(errcat.WithOnCall(r.Record))
+	for _, c := range callers {
+		if _, err := d.RegisterCaller(c); err != nil {
+			panic(err)
+		}
+	}
+	return d, r
+}
+
+// Record records the call.
+func (r *Recorder) Record(call errcatapi.Call) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.calls = append(r.calls, call)
+}
+
+// RecordCalls records the calls of the request, allowing the recorder
+// to be used as the client of a daemon.
+func (r *Recorder) RecordCalls(_ context.Context, req errcatapi.RecordCallsRequest) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.calls = append(r.calls, req.Calls...)
+	return nil
+}
+
+// Close implements errcatapi.Client.
+func (r *Recorder) Close() error {
+	return nil
+}
+
+// Calls returns the calls recorded so far, in the order they completed.
+func (r *Recorder) Calls() []errcatapi.Call {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	calls := make([]errcatapi.Call, len(r.calls))
+	copy(calls, r.calls)
+	return calls
+}
+
+// CallsFor returns the calls recorded for the caller with the key, e.g.
+// "mysql:users.GetUser", in the order they completed.
+func (r *Recorder) CallsFor(key string) []errcatapi.Call {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var calls []errcatapi.Call
+	for _, call := range r.calls {
+		if Key(call) == key {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset discards the calls recorded so far.
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.calls = nil
+}
+
+// Key returns the key of the caller that made the call, which matches
+// the key returned when registering the caller with the daemon.
+func Key(call errcatapi.Call) string {
+	return fmt.Sprintf("%s:%s", call.Dependency, call.Name)
+}
@@ -0,0 +1,88 @@
+package errcattest_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go"
+	errcatapi "github.com/agschwender/errcat-go/api"
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/categorizer"
+	"github.com/agschwender/errcat-go/errcattest"
+	"github.com/agschwender/errcat-go/retrier"
+)
+
+func TestNewDaemon(t *testing.T) {
+	clock := errcattest.NewClock(time.Now())
+	d, rec := errcattest.NewDaemon(
+		errcat.New("mysql", "users.GetUser").
+			WithBreaker(breaker.New(
+				breaker.WithMaxFailures(1),
+				breaker.WithNow(clock.Now),
+				breaker.WithTimeout(time.Minute),
+			)).
+			WithRetrier(retrier.New(retrier.WithMaxAttempts(2))),
+		errcat.New("google", "clients.Google.Search"),
+	)
+
+	// Confirm the calls are recorded as they complete, without starting
+	// the daemon
+	err := d.Call("mysql:users.GetUser", func() error { return fmt.Errorf("oops") })
+	require.Error(t, err)
+	err = d.Call("google:clients.Google.Search", func() error { return nil })
+	require.NoError(t, err)
+	assert.Len(t, rec.Calls(), 2)
+
+	err = d.Call("mysql:users.GetUser", func() error { return nil })
+	assert.ErrorIs(t, err, breaker.ErrBreakerOpen)
+
+	// Confirm the clock drives the breaker timeout
+	clock.Advance(time.Minute)
+	err = d.Call("mysql:users.GetUser", func() error { return nil })
+	require.NoError(t, err)
+
+	calls := errcattest.AssertCalls(t, rec, "mysql:users.GetUser", 3)
+	errcattest.AssertRetries(t, calls[0], 1)
+	errcattest.AssertCategories(t, calls[1], categorizer.BreakerOpen)
+	errcattest.AssertAttempts(t, calls[1], 0)
+	errcattest.AssertCategories(t, calls[2])
+
+	rec.Reset()
+	assert.Empty(t, rec.Calls())
+}
+
+func TestNewDaemonDuplicateCallers(t *testing.T) {
+	assert.Panics(t, func() {
+		errcattest.NewDaemon(
+			errcat.New("mysql", "users.GetUser"),
+			errcat.New("mysql", "users.GetUser"),
+		)
+	})
+}
+
+func TestRecorderAsClient(t *testing.T) {
+	rec := errcattest.NewRecorder()
+	d := errcat.NewD(errcat.WithClient(rec))
+	_, err := d.RegisterCaller(errcat.New("mysql", "users.GetUser"))
+	require.NoError(t, err)
+
+	// Confirm the calls are recorded once sent by the daemon
+	d.Start()
+	d.Call("mysql:users.GetUser", func() error { return nil })
+	assert.Empty(t, rec.Calls())
+	d.Stop()
+
+	require.Eventually(t, func() bool {
+		return len(rec.CallsFor("mysql:users.GetUser")) == 1
+	}, time.Second, time.Millisecond)
+	require.NoError(t, rec.Close())
+}
+
+func TestKey(t *testing.T) {
+	call := errcatapi.Call{Dependency: "mysql", Name: "users.GetUser"}
+	assert.Equal(t, "mysql:users.GetUser", errcattest.Key(call))
+}
@@ -0,0 +1,61 @@
+package errcat
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/fallback"
+	"github.com/agschwender/errcat-go/internal/errs"
+	"github.com/agschwender/errcat-go/retrier"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+// PanicError is the error returned when the callback panics. It holds
+// the value passed to panic along with the stack trace.
+type PanicError = errs.PanicError
+
+// identifying wraps the policy so that the errors it produces are
+// identified before they are seen by any hooks or policies further up
+// the pipeline.
+func (c Caller) identifying(policy Policy) Policy {
+	return PolicyFn(func(ctx context.Context, next func(ctx context.Context) error) error {
+		err := policy.RunContext(ctx, next)
+		c.identify(err)
+		return err
+	})
+}
+
+// identify records the dependency and name of the caller on the errors
+// produced by its policies, unless a caller further down the pipeline
+// has already done so.
+func (c Caller) identify(err error) {
+	if err == nil {
+		return
+	}
+
+	var breakerErr *breaker.BreakerOpenError
+	if errors.As(err, &breakerErr) && breakerErr.Dependency == "" && breakerErr.Name == "" {
+		breakerErr.Dependency, breakerErr.Name = c.dependency, c.name
+	}
+
+	var chainErr *fallback.ChainError
+	if errors.As(err, &chainErr) && chainErr.Dependency == "" && chainErr.Name == "" {
+		chainErr.Dependency, chainErr.Name = c.dependency, c.name
+	}
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) && panicErr.Dependency == "" && panicErr.Name == "" {
+		panicErr.Dependency, panicErr.Name = c.dependency, c.name
+	}
+
+	var retriesErr *retrier.RetriesExhaustedError
+	if errors.As(err, &retriesErr) && retriesErr.Dependency == "" && retriesErr.Name == "" {
+		retriesErr.Dependency, retriesErr.Name = c.dependency, c.name
+	}
+
+	var timeoutErr *timer.TimeoutError
+	if errors.As(err, &timeoutErr) && timeoutErr.Dependency == "" && timeoutErr.Name == "" {
+		timeoutErr.Dependency, timeoutErr.Name = c.dependency, c.name
+	}
+}
@@ -0,0 +1,73 @@
+package errcat_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go"
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/retrier"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+func TestCallerErrors(t *testing.T) {
+	b := breaker.New(breaker.WithName("google"), breaker.WithMaxFailures(1))
+	c := errcat.New("google", "clients.Google.Search").
+		WithBreaker(b).
+		WithRetrier(retrier.New(retrier.WithMaxAttempts(2))).
+		WithTimeout(time.Duration(10) * time.Millisecond)
+
+	// Confirm the retries are identified with the caller
+	err := c.Call(func() error { return fmt.Errorf("oops") })
+	var retriesErr *retrier.RetriesExhaustedError
+	require.ErrorAs(t, err, &retriesErr)
+	assert.Equal(t, "google", retriesErr.Dependency)
+	assert.Equal(t, "clients.Google.Search", retriesErr.Name)
+	assert.Len(t, retriesErr.Errors, 2)
+
+	// Confirm the breaker rejection is identified with the caller
+	err = c.Call(func() error { return nil })
+	assert.ErrorIs(t, err, breaker.ErrBreakerOpen)
+	var breakerErr *breaker.BreakerOpenError
+	require.ErrorAs(t, err, &breakerErr)
+	assert.Equal(t, "google", breakerErr.Breaker)
+	assert.Equal(t, "google", breakerErr.Dependency)
+	assert.Equal(t, "clients.Google.Search", breakerErr.Name)
+
+	// Confirm the timeout is identified with the caller
+	c = errcat.New("google", "clients.Google.Lookup").
+		WithTimeout(time.Duration(10) * time.Millisecond)
+	err = c.CallContext(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, timer.ErrTimeout)
+	assert.Equal(t, "google:clients.Google.Lookup: timeout exceeded after 10ms", err.Error())
+
+	// Confirm the panic is identified with the caller
+	err = c.Call(func() error { panic("oops") })
+	var panicErr *errcat.PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "oops", panicErr.Value)
+	assert.NotEmpty(t, panicErr.Stack)
+	assert.Equal(t, "google:clients.Google.Lookup: oops", err.Error())
+}
+
+func TestDaemonPanicError(t *testing.T) {
+	d := errcat.NewD()
+	key, err := d.RegisterCaller(errcat.New("mysql", "users.GetUser"))
+	require.NoError(t, err)
+
+	err = d.Call(key, func() error { panic("oops") })
+	var panicErr *errcat.PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "mysql", panicErr.Dependency)
+	assert.Equal(t, "users.GetUser", panicErr.Name)
+	assert.Equal(t, "oops", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestDaemonPanicError")
+}
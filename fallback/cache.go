@@ -0,0 +1,151 @@
+package fallback
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultCacheSize = 1000
+
+// ErrNotCached is returned by the fallback of a cache when it holds no
+// value for the key.
+var ErrNotCached = errors.New("no cached value")
+
+// Cache is a fallback that serves the last successful value of each key
+// when a call fails, so that slightly old data may be shown rather than
+// an error. It shares the options of Fallback for determining when it
+// should be used, and is named "cache" unless named otherwise.
+//
+// The cache holds at most size values, evicting the least recently
+// stored once full. Values older than the max staleness are never
+// served.
+type Cache[T any] struct {
+	fallback     *Fallback
+	maxStaleness time.Duration
+	size         int
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type cacheEntry[T any] struct {
+	key      string
+	storedAt time.Time
+	v        T
+}
+
+// NewCache creates a new Cache holding at most size values, each served
+// for at most maxStaleness after it was stored. A size of zero holds
+// 1000 values and a max staleness of zero serves values regardless of
+// their age.
+func NewCache[T any](size int, maxStaleness time.Duration, opts ...option) *Cache[T] {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+
+	return &Cache[T]{
+		fallback:     New(nil, append([]option{WithName("cache")}, opts...)...),
+		maxStaleness: maxStaleness,
+		size:         size,
+		entries:      make(map[string]*list.Element),
+		order:        list.New(),
+	}
+}
+
+// Name returns the name of the cache.
+func (c *Cache[T]) Name() string {
+	if c == nil {
+		return ""
+	}
+	return c.fallback.Name()
+}
+
+// Fallback creates a typed fallback that supplies the last successful
+// value of the key, allowing the cache to be used as a tier of a chain.
+// It returns ErrNotCached when no value is available.
+func (c *Cache[T]) Fallback(key string) *Typed[T] {
+	return &Typed[T]{
+		fallback: c.fallback,
+		call: func(context.Context, error) (T, error) {
+			v, _, ok := c.Load(key)
+			if !ok {
+				return v, fmt.Errorf("%w for %q", ErrNotCached, key)
+			}
+			return v, nil
+		},
+	}
+}
+
+// UseFallback will indicate if a stored value should be served in place
+// of the error.
+func (c *Cache[T]) UseFallback(err error) bool {
+	return c != nil && c.fallback.UseFallback(err)
+}
+
+// Store records the value as the last successful value of the key.
+func (c *Cache[T]) Store(key string, v T) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry := &cacheEntry[T]{key: key, storedAt: c.fallback.now(), v: v}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*cacheEntry[T]).key)
+	}
+}
+
+// Load returns the last successful value of the key along with its age.
+// Values older than the max staleness are discarded rather than
+// returned.
+func (c *Cache[T]) Load(key string) (v T, age time.Duration, ok bool) {
+	if c == nil {
+		return v, 0, false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return v, 0, false
+	}
+
+	entry := e.Value.(*cacheEntry[T])
+	age = c.fallback.now().Sub(entry.storedAt)
+	if c.maxStaleness > 0 && age > c.maxStaleness {
+		c.order.Remove(e)
+		delete(c.entries, key)
+		return v, 0, false
+	}
+	return entry.v, age, true
+}
+
+// Len returns the number of values held by the cache, including those
+// that have grown too stale to be served but have not been discarded.
+func (c *Cache[T]) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.order.Len()
+}
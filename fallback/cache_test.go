@@ -0,0 +1,84 @@
+package fallback_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go/fallback"
+)
+
+func TestCacheAsNil(t *testing.T) {
+	var c *fallback.Cache[string]
+
+	c.Store("alice", "Alice")
+	_, _, ok := c.Load("alice")
+	assert.False(t, ok)
+	assert.False(t, c.UseFallback(fmt.Errorf("oops")))
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	c := fallback.NewCache[string](2, time.Minute, fallback.WithNow(func() time.Time { return now }))
+
+	assert.True(t, c.UseFallback(fmt.Errorf("oops")))
+	assert.False(t, c.UseFallback(nil))
+
+	_, _, ok := c.Load("alice")
+	assert.False(t, ok)
+
+	c.Store("alice", "Alice")
+	now = now.Add(time.Duration(30) * time.Second)
+	v, age, ok := c.Load("alice")
+	require.True(t, ok)
+	assert.Equal(t, "Alice", v)
+	assert.Equal(t, time.Duration(30)*time.Second, age)
+
+	// Confirm storing again replaces the value and resets its age
+	c.Store("alice", "Alice Smith")
+	v, age, ok = c.Load("alice")
+	require.True(t, ok)
+	assert.Equal(t, "Alice Smith", v)
+	assert.Equal(t, time.Duration(0), age)
+
+	// Confirm values that are too stale are discarded
+	now = now.Add(time.Duration(61) * time.Second)
+	_, _, ok = c.Load("alice")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := fallback.NewCache[int](2, 0)
+
+	c.Store("a", 1)
+	c.Store("b", 2)
+	c.Store("a", 3)
+	c.Store("c", 4)
+	assert.Equal(t, 2, c.Len())
+
+	// The least recently stored value is evicted
+	_, _, ok := c.Load("b")
+	assert.False(t, ok)
+
+	v, _, ok := c.Load("a")
+	require.True(t, ok)
+	assert.Equal(t, 3, v)
+
+	v, _, ok = c.Load("c")
+	require.True(t, ok)
+	assert.Equal(t, 4, v)
+}
+
+func TestCacheWithUseFallback(t *testing.T) {
+	c := fallback.NewCache[int](0, 0, fallback.WithUseFallback(func(err error) bool {
+		return err != nil && err.Error() != "not found"
+	}))
+
+	assert.True(t, c.UseFallback(fmt.Errorf("oops")))
+	assert.False(t, c.UseFallback(fmt.Errorf("not found")))
+}
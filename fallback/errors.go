@@ -0,0 +1,55 @@
+package fallback
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/agschwender/errcat-go/internal/errs"
+)
+
+// ChainError is returned when every fallback of a chain that applied to
+// the error of a call failed. The first error is that of the call,
+// followed by the error of each fallback in the order they were tried.
+type ChainError struct {
+	// Dependency and Name identify the caller that made the call, when
+	// the chain is used by one.
+	Dependency string
+	Name       string
+
+	Errors []error
+}
+
+func (e *ChainError) Error() string {
+	return errs.Describe(e.Dependency, e.Name, fmt.Sprintf(
+		"fallbacks exhausted after %d tiers: %v", len(e.Errors)-1, e.Unwrap(),
+	))
+}
+
+// Unwrap returns the error of the last fallback tried.
+func (e *ChainError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors[len(e.Errors)-1]
+}
+
+// Is reports whether any of the errors match the target, so that the
+// cause of a failure is found regardless of the tier it occurred in.
+func (e *ChainError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches the target.
+func (e *ChainError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,12 +1,29 @@
 package fallback
 
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"time"
+)
+
 var defaultUseFallback = func(err error) bool { return err != nil }
 
 type FallbackFn func() error
 
+// FallbackContextFn is the context aware variant of FallbackFn. It
+// receives the context of the call, so that it may honor its deadline,
+// along with the error that triggered the fallback. Returning the error
+// unchanged declines to fall back, e.g. when the error is not one the
+// fallback can recover from.
+type FallbackContextFn func(ctx context.Context, err error) error
+
 type Fallback struct {
 	useFallback func(err error) bool
-	call        FallbackFn
+	call        FallbackContextFn
+	name        string
+	now         func() time.Time
+	steps       []*Fallback
 }
 
 type option func(*Fallback)
@@ -17,9 +34,21 @@ func New(call FallbackFn, opts ...option) *Fallback {
 		call = func() error { return nil }
 	}
 
+	return NewContext(func(context.Context, error) error {
+		return call()
+	}, opts...)
+}
+
+// NewContext creates a new Fallback with the context aware function.
+func NewContext(call FallbackContextFn, opts ...option) *Fallback {
+	if call == nil {
+		call = func(context.Context, error) error { return nil }
+	}
+
 	f := &Fallback{
 		useFallback: defaultUseFallback,
 		call:        call,
+		now:         time.Now,
 	}
 
 	for _, opt := range opts {
@@ -29,6 +58,35 @@ func New(call FallbackFn, opts ...option) *Fallback {
 	return f
 }
 
+// Chain creates a Fallback that tries each of the fallbacks in order
+// until one succeeds, e.g. a replica, then a cache, then a static
+// default. A fallback is skipped when it does not apply to the error of
+// the call. When every fallback that applies fails, a ChainError
+// holding the error of the call and of each fallback is returned. A
+// fallback that declines is not counted as having failed.
+func Chain(fallbacks ...*Fallback) *Fallback {
+	f := New(nil)
+	for _, step := range fallbacks {
+		if step != nil {
+			f.steps = append(f.steps, step)
+		}
+	}
+
+	f.useFallback = func(err error) bool {
+		for _, step := range f.steps {
+			if step.UseFallback(err) {
+				return true
+			}
+		}
+		return false
+	}
+	f.call = func(ctx context.Context, err error) error {
+		_, _, err = useChain(ctx, f.steps, err, func(*Fallback, error) bool { return true }, useStep)
+		return err
+	}
+	return f
+}
+
 // WithUseFallback sets those errors that should trigger a fallback.
 func WithUseFallback(useFallback func(err error) bool) option {
 	return func(f *Fallback) {
@@ -39,16 +97,245 @@ func WithUseFallback(useFallback func(err error) bool) option {
 	}
 }
 
+// WithName names the fallback. The name identifies the fallback tier
+// that produced the result of a call when it is reported. By default,
+// a fallback is identified by its position in a chain, starting at 1.
+func WithName(name string) option {
+	return func(f *Fallback) {
+		f.name = name
+	}
+}
+
+// WithNow sets the function for getting the current time, which the
+// Cache uses to determine the age of its values. This is only useful
+// for testing.
+func WithNow(now func() time.Time) option {
+	return func(f *Fallback) {
+		if now == nil {
+			now = time.Now
+		}
+		f.now = now
+	}
+}
+
+// Name returns the name of the fallback.
+func (f *Fallback) Name() string {
+	if f == nil {
+		return ""
+	}
+	return f.name
+}
+
 // UseFallback will indicate if the fallback should be called.
 func (f *Fallback) UseFallback(err error) bool {
 	return f != nil && f.useFallback(err)
 }
 
 // Call will execute the fallback function. Callers should check
-// UseFallback prior to calling.
+// UseFallback prior to calling. Since the error is not known, a chain
+// tries each of its fallbacks regardless of whether they apply; Use
+// should be preferred.
 func (f *Fallback) Call() error {
+	return f.CallContext(context.Background(), nil)
+}
+
+// CallContext will execute the fallback function with the context and
+// the error that triggered it. Callers should check UseFallback prior
+// to calling.
+func (f *Fallback) CallContext(ctx context.Context, err error) error {
 	if f == nil {
 		return nil
 	}
-	return f.call()
+	return f.call(ctx, err)
+}
+
+// Use will execute the fallback in place of the error, returning the
+// fallback tier that produced the result along with the result. For a
+// chain, only the fallbacks that apply to the error are tried. When the
+// fallback declines, the tier is empty and the error is returned as is.
+// Callers should check UseFallback prior to using.
+func (f *Fallback) Use(ctx context.Context, err error) (string, error) {
+	if f == nil {
+		return "", nil
+	}
+	_, tier, err := useStep(ctx, f, err, 1)
+	return tier, err
+}
+
+func useStep(ctx context.Context, f *Fallback, err error, position int) (struct{}, string, error) {
+	if f.steps != nil {
+		return useChain(ctx, f.steps, err, (*Fallback).UseFallback, useStep)
+	}
+
+	result := f.call(ctx, err)
+	if declined(result, err) {
+		return struct{}{}, "", err
+	}
+	return struct{}{}, tierName(f.name, position), result
+}
+
+// useChain tries each of the steps that apply to the error in order,
+// returning the value and tier of the first to succeed.
+func useChain[S any, T any](
+	ctx context.Context,
+	steps []S,
+	err error,
+	applies func(S, error) bool,
+	use func(context.Context, S, error, int) (T, string, error),
+) (T, string, error) {
+	var zero T
+	errs := []error{err}
+	for i, step := range steps {
+		if !applies(step, err) {
+			continue
+		}
+
+		v, tier, stepErr := use(ctx, step, err, i+1)
+		if stepErr == nil {
+			return v, tier, nil
+		}
+		if !declined(stepErr, err) {
+			errs = append(errs, stepErr)
+		}
+	}
+
+	if len(errs) == 1 {
+		return zero, "", err
+	}
+	return zero, "", &ChainError{Errors: errs}
+}
+
+// declined reports whether the fallback returned the error that
+// triggered it, indicating it does not apply.
+func declined(result, err error) bool {
+	return err != nil && result != nil && reflect.TypeOf(err).Comparable() && result == err
+}
+
+func tierName(name string, position int) string {
+	if name != "" {
+		return name
+	}
+	return strconv.Itoa(position)
+}
+
+// TypedFallbackFn is the typed variant of FallbackFn. It supplies a
+// substitute value in place of the one the call failed to produce.
+type TypedFallbackFn[T any] func() (T, error)
+
+// TypedFallbackContextFn is the typed variant of FallbackContextFn.
+type TypedFallbackContextFn[T any] func(ctx context.Context, err error) (T, error)
+
+// Typed is a fallback that supplies a substitute value of type T. It
+// shares the options of Fallback for determining when it should be
+// used.
+type Typed[T any] struct {
+	fallback *Fallback
+	call     TypedFallbackContextFn[T]
+	steps    []*Typed[T]
+}
+
+// NewTyped creates a new Typed fallback with the supplied options.
+func NewTyped[T any](call TypedFallbackFn[T], opts ...option) *Typed[T] {
+	if call == nil {
+		return NewTypedContext[T](nil, opts...)
+	}
+
+	return NewTypedContext(func(context.Context, error) (T, error) {
+		return call()
+	}, opts...)
+}
+
+// NewTypedContext creates a new Typed fallback with the context aware
+// function.
+func NewTypedContext[T any](call TypedFallbackContextFn[T], opts ...option) *Typed[T] {
+	if call == nil {
+		call = func(context.Context, error) (T, error) {
+			var zero T
+			return zero, nil
+		}
+	}
+
+	return &Typed[T]{
+		fallback: New(nil, opts...),
+		call:     call,
+	}
+}
+
+// Value creates a new Typed fallback that always supplies the value v.
+func Value[T any](v T, opts ...option) *Typed[T] {
+	return NewTyped(func() (T, error) { return v, nil }, opts...)
+}
+
+// ChainTyped is the typed variant of Chain.
+func ChainTyped[T any](fallbacks ...*Typed[T]) *Typed[T] {
+	f := NewTyped[T](nil)
+	for _, step := range fallbacks {
+		if step != nil {
+			f.steps = append(f.steps, step)
+		}
+	}
+
+	f.fallback.useFallback = func(err error) bool {
+		for _, step := range f.steps {
+			if step.UseFallback(err) {
+				return true
+			}
+		}
+		return false
+	}
+	f.call = func(ctx context.Context, err error) (T, error) {
+		v, _, err := useChain(ctx, f.steps, err, func(*Typed[T], error) bool { return true }, useTypedStep[T])
+		return v, err
+	}
+	return f
+}
+
+// Name returns the name of the fallback.
+func (f *Typed[T]) Name() string {
+	if f == nil {
+		return ""
+	}
+	return f.fallback.Name()
+}
+
+// UseFallback will indicate if the fallback should be called.
+func (f *Typed[T]) UseFallback(err error) bool {
+	return f != nil && f.fallback.UseFallback(err)
+}
+
+// Call will execute the fallback function. Callers should check
+// UseFallback prior to calling.
+func (f *Typed[T]) Call() (T, error) {
+	return f.CallContext(context.Background(), nil)
+}
+
+// CallContext is the typed variant of Fallback.CallContext.
+func (f *Typed[T]) CallContext(ctx context.Context, err error) (T, error) {
+	if f == nil {
+		var zero T
+		return zero, nil
+	}
+	return f.call(ctx, err)
+}
+
+// Use is the typed variant of Fallback.Use.
+func (f *Typed[T]) Use(ctx context.Context, err error) (T, string, error) {
+	if f == nil {
+		var zero T
+		return zero, "", nil
+	}
+	return useTypedStep(ctx, f, err, 1)
+}
+
+func useTypedStep[T any](ctx context.Context, f *Typed[T], err error, position int) (T, string, error) {
+	if f.steps != nil {
+		return useChain(ctx, f.steps, err, (*Typed[T]).UseFallback, useTypedStep[T])
+	}
+
+	v, result := f.call(ctx, err)
+	if declined(result, err) {
+		var zero T
+		return zero, "", err
+	}
+	return v, tierName(f.fallback.name, position), result
 }
@@ -1,10 +1,12 @@
 package fallback_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/agschwender/errcat-go/fallback"
 )
@@ -48,3 +50,229 @@ func TestWithZeroValues(t *testing.T) {
 	assert.False(t, f.UseFallback(nil))
 	assert.Nil(t, f.Call())
 }
+
+func TestTypedAsNil(t *testing.T) {
+	var f *fallback.Typed[string]
+
+	assert.False(t, f.UseFallback(fmt.Errorf("oops")))
+	assert.False(t, f.UseFallback(nil))
+
+	v, err := f.Call()
+	assert.Nil(t, err)
+	assert.Equal(t, "", v)
+}
+
+func TestTypedWithDefaults(t *testing.T) {
+	f := fallback.NewTyped[int](nil)
+
+	assert.True(t, f.UseFallback(fmt.Errorf("oops")))
+	assert.False(t, f.UseFallback(nil))
+
+	v, err := f.Call()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, v)
+}
+
+func TestTypedWithOverrides(t *testing.T) {
+	f := fallback.NewTyped(func() (int, error) {
+		return 0, fmt.Errorf("fallback failed")
+	}, fallback.WithUseFallback(func(err error) bool {
+		return err != nil && err.Error() != "no fallback"
+	}))
+
+	assert.True(t, f.UseFallback(fmt.Errorf("oops")))
+	assert.False(t, f.UseFallback(fmt.Errorf("no fallback")))
+
+	_, err := f.Call()
+	assert.Equal(t, "fallback failed", err.Error())
+}
+
+func TestValue(t *testing.T) {
+	f := fallback.Value("default")
+
+	assert.True(t, f.UseFallback(fmt.Errorf("oops")))
+	assert.False(t, f.UseFallback(nil))
+
+	v, err := f.Call()
+	assert.Nil(t, err)
+	assert.Equal(t, "default", v)
+}
+
+func TestChain(t *testing.T) {
+	var tried []string
+	step := func(name string, err error) *fallback.Fallback {
+		return fallback.New(func() error {
+			tried = append(tried, name)
+			return err
+		}, fallback.WithName(name))
+	}
+
+	errNotFound := fmt.Errorf("not found")
+	f := fallback.Chain(
+		step("replica", fmt.Errorf("replica down")),
+		fallback.New(func() error {
+			tried = append(tried, "unnamed")
+			return fmt.Errorf("oops")
+		}, fallback.WithUseFallback(func(err error) bool {
+			return err != nil && err != errNotFound
+		})),
+		nil,
+		step("default", nil),
+	)
+
+	assert.True(t, f.UseFallback(fmt.Errorf("oops")))
+	assert.False(t, f.UseFallback(nil))
+
+	tier, err := f.Use(context.Background(), fmt.Errorf("oops"))
+	require.NoError(t, err)
+	assert.Equal(t, "default", tier)
+	assert.Equal(t, []string{"replica", "unnamed", "default"}, tried)
+
+	// Confirm fallbacks that do not apply are skipped
+	tried = nil
+	tier, err = f.Use(context.Background(), errNotFound)
+	require.NoError(t, err)
+	assert.Equal(t, "default", tier)
+	assert.Equal(t, []string{"replica", "default"}, tried)
+
+	// Confirm Call tries every fallback
+	tried = nil
+	assert.NoError(t, f.Call())
+	assert.Equal(t, []string{"replica", "unnamed", "default"}, tried)
+}
+
+func TestChainError(t *testing.T) {
+	errReplica := fmt.Errorf("replica down")
+	f := fallback.Chain(
+		fallback.New(func() error { return errReplica }),
+		fallback.New(func() error { return fmt.Errorf("cache miss") }),
+	)
+
+	errCall := fmt.Errorf("oops")
+	tier, err := f.Use(context.Background(), errCall)
+	assert.Equal(t, "", tier)
+	assert.EqualError(t, err, "fallbacks exhausted after 2 tiers: cache miss")
+	assert.ErrorIs(t, err, errCall)
+	assert.ErrorIs(t, err, errReplica)
+
+	var chainErr *fallback.ChainError
+	require.ErrorAs(t, err, &chainErr)
+	assert.Len(t, chainErr.Errors, 3)
+
+	// Confirm the error is returned as is when no fallback applies
+	f = fallback.Chain(fallback.New(nil, fallback.WithUseFallback(func(error) bool { return false })))
+	_, err = f.Use(context.Background(), errCall)
+	assert.Equal(t, errCall, err)
+}
+
+func TestUseTiers(t *testing.T) {
+	tier, err := fallback.New(nil).Use(context.Background(), fmt.Errorf("oops"))
+	require.NoError(t, err)
+	assert.Equal(t, "1", tier)
+
+	tier, err = fallback.Chain(
+		fallback.New(func() error { return fmt.Errorf("oops") }),
+		fallback.New(nil),
+	).Use(context.Background(), fmt.Errorf("oops"))
+	require.NoError(t, err)
+	assert.Equal(t, "2", tier)
+
+	var f *fallback.Fallback
+	tier, err = f.Use(context.Background(), fmt.Errorf("oops"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", tier)
+}
+
+func TestChainTyped(t *testing.T) {
+	cache := fallback.NewCache[string](0, 0)
+	cache.Store("alice", "Alice")
+
+	f := fallback.ChainTyped(
+		fallback.NewTyped(func() (string, error) {
+			return "", fmt.Errorf("replica down")
+		}, fallback.WithName("replica")),
+		cache.Fallback("bob"),
+		cache.Fallback("alice"),
+		fallback.Value("unknown", fallback.WithName("default")),
+	)
+
+	v, tier, err := f.Use(context.Background(), fmt.Errorf("oops"))
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", v)
+	assert.Equal(t, "cache", tier)
+
+	v, err = f.Call()
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", v)
+
+	_, err = cache.Fallback("bob").Call()
+	assert.ErrorIs(t, err, fallback.ErrNotCached)
+}
+
+func TestNewContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "request")
+
+	errNotFound := fmt.Errorf("not found")
+	f := fallback.NewContext(func(ctx context.Context, err error) error {
+		assert.Equal(t, "request", ctx.Value(key{}))
+		if err == errNotFound {
+			return err
+		}
+		return nil
+	}, fallback.WithName("default"))
+
+	assert.NoError(t, f.CallContext(ctx, fmt.Errorf("oops")))
+
+	tier, err := f.Use(ctx, fmt.Errorf("oops"))
+	assert.NoError(t, err)
+	assert.Equal(t, "default", tier)
+
+	// Confirm returning the error declines to fall back
+	tier, err = f.Use(ctx, errNotFound)
+	assert.Equal(t, errNotFound, err)
+	assert.Equal(t, "", tier)
+
+	f = fallback.NewContext(nil)
+	assert.NoError(t, f.CallContext(ctx, fmt.Errorf("oops")))
+}
+
+func TestChainDecline(t *testing.T) {
+	errCall := fmt.Errorf("oops")
+	decline := fallback.NewContext(func(_ context.Context, err error) error { return err })
+
+	f := fallback.Chain(decline, fallback.New(nil, fallback.WithName("default")))
+	tier, err := f.Use(context.Background(), errCall)
+	require.NoError(t, err)
+	assert.Equal(t, "default", tier)
+
+	// Confirm declining fallbacks are not counted as failures
+	f = fallback.Chain(decline, fallback.New(func() error { return fmt.Errorf("no default") }))
+	_, err = f.Use(context.Background(), errCall)
+	assert.EqualError(t, err, "fallbacks exhausted after 1 tiers: no default")
+
+	f = fallback.Chain(decline, decline)
+	tier, err = f.Use(context.Background(), errCall)
+	assert.Equal(t, errCall, err)
+	assert.Equal(t, "", tier)
+}
+
+func TestNewTypedContext(t *testing.T) {
+	errNotFound := fmt.Errorf("not found")
+	f := fallback.NewTypedContext(func(ctx context.Context, err error) (string, error) {
+		if err == errNotFound {
+			return "", err
+		}
+		return "unknown", nil
+	})
+
+	v, tier, err := f.Use(context.Background(), fmt.Errorf("oops"))
+	require.NoError(t, err)
+	assert.Equal(t, "unknown", v)
+	assert.Equal(t, "1", tier)
+
+	v, tier, err = f.Use(context.Background(), errNotFound)
+	assert.Equal(t, errNotFound, err)
+	assert.Equal(t, "", v)
+	assert.Equal(t, "", tier)
+}
@@ -0,0 +1,55 @@
+package fallback
+
+import (
+	"errors"
+
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/categorizer"
+)
+
+var defaultCategorizer = categorizer.New()
+
+// OnBreakerOpen is a predicate for WithUseFallback that falls back when
+// the call was rejected by an open circuit breaker.
+func OnBreakerOpen(err error) bool {
+	return errors.Is(err, breaker.ErrBreakerOpen)
+}
+
+// OnTimeout is a predicate for WithUseFallback that falls back when the
+// call timed out, whether by the caller's timer, the deadline of the
+// context or the network.
+func OnTimeout(err error) bool {
+	return OnCategories(categorizer.Timeout)(err)
+}
+
+// OnCategories creates a predicate for WithUseFallback that falls back
+// when the error is in any of the categories, as determined by the
+// built-in rules of the categorizer.
+func OnCategories(categories ...string) func(err error) bool {
+	return OnCategoriesOf(defaultCategorizer, categories...)
+}
+
+// OnCategoriesOf is the variant of OnCategories that determines the
+// categories of the error using the categorizer.
+func OnCategoriesOf(c *categorizer.Categorizer, categories ...string) func(err error) bool {
+	if c == nil {
+		c = defaultCategorizer
+	}
+
+	matches := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		matches[category] = true
+	}
+
+	return func(err error) bool {
+		if err == nil {
+			return false
+		}
+		for _, category := range c.Categorize(err) {
+			if matches[category] {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,47 @@
+package fallback_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/categorizer"
+	"github.com/agschwender/errcat-go/fallback"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+func TestOnBreakerOpen(t *testing.T) {
+	assert.True(t, fallback.OnBreakerOpen(&breaker.BreakerOpenError{}))
+	assert.True(t, fallback.OnBreakerOpen(fmt.Errorf("wrapped: %w", breaker.ErrBreakerOpen)))
+	assert.False(t, fallback.OnBreakerOpen(fmt.Errorf("oops")))
+	assert.False(t, fallback.OnBreakerOpen(nil))
+}
+
+func TestOnTimeout(t *testing.T) {
+	assert.True(t, fallback.OnTimeout(&timer.TimeoutError{}))
+	assert.True(t, fallback.OnTimeout(context.DeadlineExceeded))
+	assert.False(t, fallback.OnTimeout(breaker.ErrBreakerOpen))
+	assert.False(t, fallback.OnTimeout(nil))
+}
+
+func TestOnCategories(t *testing.T) {
+	onCategories := fallback.OnCategories(categorizer.Timeout, categorizer.BreakerOpen)
+	assert.True(t, onCategories(context.DeadlineExceeded))
+	assert.True(t, onCategories(breaker.ErrBreakerOpen))
+	assert.False(t, onCategories(fmt.Errorf("oops")))
+	assert.False(t, onCategories(nil))
+
+	errNotFound := fmt.Errorf("not found")
+	onNotFound := fallback.OnCategoriesOf(categorizer.New(
+		categorizer.WithRules(categorizer.Is(errNotFound, "not_found")),
+	), "not_found")
+	assert.True(t, onNotFound(fmt.Errorf("user: %w", errNotFound)))
+	assert.False(t, onNotFound(context.DeadlineExceeded))
+
+	f := fallback.New(nil, fallback.WithUseFallback(fallback.OnBreakerOpen))
+	assert.True(t, f.UseFallback(breaker.ErrBreakerOpen))
+	assert.False(t, f.UseFallback(fmt.Errorf("oops")))
+}
@@ -0,0 +1,34 @@
+package errcat
+
+import (
+	"context"
+
+	"github.com/agschwender/errcat-go/faults"
+)
+
+type faultsKey struct{}
+
+func withFaults(ctx context.Context, i *faults.Injector) context.Context {
+	return context.WithValue(ctx, faultsKey{}, i)
+}
+
+func faultsFrom(ctx context.Context) *faults.Injector {
+	i, _ := ctx.Value(faultsKey{}).(*faults.Injector)
+	return i
+}
+
+// injectFaults wraps the callback so that the faults of the daemon's
+// injector are injected ahead of it. Since the callback is wrapped
+// within the caller's policies, a fault is injected into each attempt
+// and the policies react to it as they would to a real failure.
+func (c Caller) injectFaults(cb CallContextFn) CallContextFn {
+	return func(ctx context.Context) error {
+		rule, ok := faultsFrom(ctx).Match(c.key)
+		if !ok {
+			return cb(ctx)
+		}
+
+		recorderFrom(ctx).injected(rule.Name)
+		return rule.Inject(ctx, cb)
+	}
+}
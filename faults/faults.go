@@ -0,0 +1,324 @@
+// Package faults injects latency, errors and panics into calls, so that
+// outages of a dependency may be rehearsed without changing code. An
+// Injector holds the rules that describe the faults; once attached to a
+// daemon with errcat.WithFaults, the faults are injected ahead of the
+// callback of each matching call, such that the caller's policies react
+// to them as they would to a real outage.
+package faults
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+var defaultRand = rand.Float64
+
+// Rule describes a fault and the calls it is injected into.
+type Rule struct {
+	// Name identifies the rule and is reported with the calls the fault
+	// is injected into.
+	Name string
+
+	// Key is the key of the caller, e.g. "mysql:users.GetUser", or a
+	// pattern matching the keys of multiple callers, e.g. "mysql:*". See
+	// path.Match for the syntax of patterns.
+	Key string
+
+	// Probability is the chance, between 0 and 1, the fault is injected
+	// into a matching call. Zero injects the fault into every call.
+	Probability float64
+
+	// Delay is added ahead of the call, or of the error or panic.
+	Delay time.Duration
+
+	// Error is returned in place of calling the dependency. Unless the
+	// rule panics, the dependency is called after the delay when no
+	// error is set.
+	Error error
+
+	// Panic indicates the call should panic, with the error if one is
+	// set, in place of calling the dependency.
+	Panic bool
+
+	// Start and End limit the time the rule is active. Zero values do
+	// not limit it.
+	Start time.Time
+	End   time.Time
+}
+
+// Injector injects the faults described by its rules. It is safe for
+// concurrent use, so rules may be added and removed while calls are
+// being made.
+type Injector struct {
+	now  func() time.Time
+	rand func() float64
+
+	lock     sync.RWMutex
+	disabled bool
+	rules    []Rule
+}
+
+type option func(*Injector)
+
+// New creates a new Injector without any rules.
+func New(opts ...option) *Injector {
+	i := &Injector{
+		now:  time.Now,
+		rand: defaultRand,
+	}
+
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	return i
+}
+
+// WithNow sets the function for getting the current time. This is only
+// useful for testing.
+func WithNow(now func() time.Time) option {
+	return func(i *Injector) {
+		if now == nil {
+			now = time.Now
+		}
+		i.now = now
+	}
+}
+
+// WithRand sets the function for getting a random number between 0 and
+// 1, which determines whether a fault is injected. This is only useful
+// for testing.
+func WithRand(rand func() float64) option {
+	return func(i *Injector) {
+		if rand == nil {
+			rand = defaultRand
+		}
+		i.rand = rand
+	}
+}
+
+// Add adds the rules, replacing any existing rules with the same names.
+// No rules are added if any of them are invalid.
+func (i *Injector) Add(rules ...Rule) error {
+	if i == nil {
+		return nil
+	}
+
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	byName := make(map[string]Rule, len(i.rules)+len(rules))
+	for _, rule := range i.rules {
+		byName[rule.Name] = rule
+	}
+	for _, rule := range rules {
+		byName[rule.Name] = rule
+	}
+	i.rules = sortedRules(byName)
+	return nil
+}
+
+// Remove removes the rules with the names.
+func (i *Injector) Remove(names ...string) {
+	if i == nil {
+		return
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	remove := make(map[string]bool, len(names))
+	for _, name := range names {
+		remove[name] = true
+	}
+
+	rules := make([]Rule, 0, len(i.rules))
+	for _, rule := range i.rules {
+		if !remove[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+	i.rules = rules
+}
+
+// SetRules replaces all of the rules. The existing rules are kept if any
+// of the new rules are invalid.
+func (i *Injector) SetRules(rules ...Rule) error {
+	if i == nil {
+		return nil
+	}
+
+	byName := make(map[string]Rule, len(rules))
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+		if _, ok := byName[rule.Name]; ok {
+			return fmt.Errorf("fault %q: must not be defined more than once", rule.Name)
+		}
+		byName[rule.Name] = rule
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.rules = sortedRules(byName)
+	return nil
+}
+
+// Rules returns the rules, sorted by name.
+func (i *Injector) Rules() []Rule {
+	if i == nil {
+		return nil
+	}
+
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	rules := make([]Rule, len(i.rules))
+	copy(rules, i.rules)
+	return rules
+}
+
+// Enable resumes injecting faults after Disable.
+func (i *Injector) Enable() {
+	if i == nil {
+		return
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.disabled = false
+}
+
+// Disable stops injecting faults without removing the rules.
+func (i *Injector) Disable() {
+	if i == nil {
+		return
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.disabled = true
+}
+
+// Enabled indicates whether faults are being injected.
+func (i *Injector) Enabled() bool {
+	if i == nil {
+		return false
+	}
+
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	return !i.disabled
+}
+
+// Match returns the first active rule, in order of name, that matches
+// the caller with the key, having rolled the probability of each.
+func (i *Injector) Match(key string) (Rule, bool) {
+	if i == nil {
+		return Rule{}, false
+	}
+
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	if i.disabled || len(i.rules) == 0 {
+		return Rule{}, false
+	}
+
+	now := i.now()
+	for _, rule := range i.rules {
+		if matched, _ := path.Match(rule.Key, key); !matched {
+			continue
+		}
+		if !rule.Start.IsZero() && now.Before(rule.Start) {
+			continue
+		}
+		if !rule.End.IsZero() && !now.Before(rule.End) {
+			continue
+		}
+		if rule.Probability > 0 && i.rand() >= rule.Probability {
+			continue
+		}
+		return rule, true
+	}
+	return Rule{}, false
+}
+
+// Inject injects the fault of the rule into the call, running the
+// callback only when the rule adds a delay alone. The delay is cut short
+// when the context is done.
+func (r Rule) Inject(ctx context.Context, cb func(ctx context.Context) error) error {
+	if r.Delay > 0 {
+		timer := time.NewTimer(r.Delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	switch {
+	case r.Panic && r.Error != nil:
+		panic(r.Error)
+	case r.Panic:
+		panic(fmt.Sprintf("injected panic: %s", r.Name))
+	case r.Error != nil:
+		return r.Error
+	default:
+		return cb(ctx)
+	}
+}
+
+func (r Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("fault: name must not be empty")
+	}
+	if r.Key == "" {
+		return fmt.Errorf("fault %q: key must not be empty", r.Name)
+	}
+	if _, err := path.Match(r.Key, ""); err != nil {
+		return fmt.Errorf("fault %q: invalid key pattern %q", r.Name, r.Key)
+	}
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("fault %q: probability must be between 0 and 1, got %v", r.Name, r.Probability)
+	}
+	if r.Delay < 0 {
+		return fmt.Errorf("fault %q: delay must not be negative", r.Name)
+	}
+	if r.Delay == 0 && r.Error == nil && !r.Panic {
+		return fmt.Errorf("fault %q: must add a delay, error or panic", r.Name)
+	}
+	if !r.Start.IsZero() && !r.End.IsZero() && !r.End.After(r.Start) {
+		return fmt.Errorf("fault %q: end must be after start", r.Name)
+	}
+	return nil
+}
+
+func sortedRules(byName map[string]Rule) []Rule {
+	rules := make([]Rule, 0, len(byName))
+	for _, rule := range byName {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Name < rules[j].Name
+	})
+	return rules
+}
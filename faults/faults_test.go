@@ -0,0 +1,157 @@
+package faults_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go/faults"
+)
+
+func TestAsNil(t *testing.T) {
+	var i *faults.Injector
+
+	assert.NoError(t, i.Add(faults.Rule{Name: "users", Key: "mysql:users.*", Panic: true}))
+	assert.NoError(t, i.SetRules(faults.Rule{Name: "users", Key: "mysql:users.*", Panic: true}))
+	i.Remove("users")
+	i.Disable()
+	i.Enable()
+
+	_, ok := i.Match("mysql:users.GetUser")
+	assert.False(t, ok)
+	assert.Nil(t, i.Rules())
+	assert.False(t, i.Enabled())
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 30, 0, 0, time.UTC)
+	roll := 0.5
+	i := faults.New(
+		faults.WithNow(func() time.Time { return now }),
+		faults.WithRand(func() float64 { return roll }),
+	)
+	require.NoError(t, i.Add(
+		faults.Rule{Name: "b-users", Key: "mysql:users.*", Delay: time.Second},
+		faults.Rule{Name: "a-window", Key: "mysql:*", Panic: true,
+			Start: time.Date(2024, time.January, 1, 1, 0, 0, 0, time.UTC),
+			End:   time.Date(2024, time.January, 1, 2, 0, 0, 0, time.UTC),
+		},
+		faults.Rule{Name: "c-search", Key: "google:clients.Google.Search", Error: fmt.Errorf("oops"), Probability: 0.25},
+	))
+
+	// Confirm the first rule to match by name is used once active
+	rule, ok := i.Match("mysql:users.GetUser")
+	require.True(t, ok)
+	assert.Equal(t, "b-users", rule.Name)
+
+	now = now.Add(time.Hour)
+	rule, ok = i.Match("mysql:users.GetUser")
+	require.True(t, ok)
+	assert.Equal(t, "a-window", rule.Name)
+
+	now = now.Add(time.Hour)
+	rule, ok = i.Match("mysql:users.GetUser")
+	require.True(t, ok)
+	assert.Equal(t, "b-users", rule.Name)
+
+	_, ok = i.Match("mysql:orders.GetOrder")
+	assert.False(t, ok)
+
+	// Confirm the probability of the rule is applied
+	_, ok = i.Match("google:clients.Google.Search")
+	assert.False(t, ok)
+	roll = 0.1
+	_, ok = i.Match("google:clients.Google.Search")
+	assert.True(t, ok)
+
+	// Confirm the rules are not matched while disabled
+	i.Disable()
+	assert.False(t, i.Enabled())
+	_, ok = i.Match("mysql:users.GetUser")
+	assert.False(t, ok)
+	i.Enable()
+	_, ok = i.Match("mysql:users.GetUser")
+	assert.True(t, ok)
+
+	// Confirm rules may be removed
+	i.Remove("b-users", "c-search")
+	_, ok = i.Match("mysql:users.GetUser")
+	assert.False(t, ok)
+	assert.Len(t, i.Rules(), 1)
+}
+
+func TestSetRules(t *testing.T) {
+	i := faults.New()
+	require.NoError(t, i.Add(faults.Rule{Name: "outage", Key: "mysql:*", Panic: true}))
+
+	tests := []struct {
+		rule faults.Rule
+		err  string
+	}{
+		{faults.Rule{Key: "mysql:*", Panic: true}, "fault: name must not be empty"},
+		{faults.Rule{Name: "outage"}, `fault "outage": key must not be empty`},
+		{faults.Rule{Name: "outage", Key: "mysql:[", Panic: true}, `fault "outage": invalid key pattern "mysql:["`},
+		{faults.Rule{Name: "outage", Key: "mysql:*", Panic: true, Probability: 1.5}, `fault "outage": probability must be between 0 and 1, got 1.5`},
+		{faults.Rule{Name: "outage", Key: "mysql:*", Delay: -time.Second}, `fault "outage": delay must not be negative`},
+		{faults.Rule{Name: "outage", Key: "mysql:*"}, `fault "outage": must add a delay, error or panic`},
+	}
+	for _, test := range tests {
+		assert.EqualError(t, i.SetRules(test.rule), test.err)
+		assert.EqualError(t, i.Add(test.rule), test.err)
+	}
+
+	err := i.SetRules(
+		faults.Rule{Name: "slow", Key: "mysql:*", Delay: time.Second},
+		faults.Rule{Name: "slow", Key: "google:*", Delay: time.Second},
+	)
+	assert.EqualError(t, err, `fault "slow": must not be defined more than once`)
+
+	// Confirm the rules are kept when invalid and replaced otherwise
+	require.Len(t, i.Rules(), 1)
+	assert.Equal(t, "outage", i.Rules()[0].Name)
+
+	require.NoError(t, i.SetRules(faults.Rule{Name: "slow", Key: "mysql:*", Delay: time.Second}))
+	require.Len(t, i.Rules(), 1)
+	assert.Equal(t, "slow", i.Rules()[0].Name)
+}
+
+func TestInject(t *testing.T) {
+	called := false
+	cb := func(context.Context) error {
+		called = true
+		return nil
+	}
+
+	// Confirm a delay alone runs the callback afterward
+	startedAt := time.Now()
+	err := faults.Rule{Name: "slow", Delay: time.Duration(10) * time.Millisecond}.Inject(context.Background(), cb)
+	require.NoError(t, err)
+	assert.True(t, called)
+	assert.GreaterOrEqual(t, time.Since(startedAt), time.Duration(10)*time.Millisecond)
+
+	// Confirm the delay is cut short by the context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(10)*time.Millisecond)
+	defer cancel()
+	err = faults.Rule{Name: "slow", Delay: time.Minute}.Inject(ctx, cb)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Confirm the error is returned in place of the callback
+	called = false
+	errOops := fmt.Errorf("oops")
+	err = faults.Rule{Name: "broken", Error: errOops}.Inject(context.Background(), cb)
+	assert.Equal(t, errOops, err)
+	assert.False(t, called)
+
+	// Confirm the call panics, with the error if set
+	assert.PanicsWithValue(t, "injected panic: crash", func() {
+		faults.Rule{Name: "crash", Panic: true}.Inject(context.Background(), cb)
+	})
+	assert.PanicsWithError(t, "oops", func() {
+		faults.Rule{Name: "crash", Panic: true, Error: errOops}.Inject(context.Background(), cb)
+	})
+	assert.False(t, called)
+}
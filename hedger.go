@@ -0,0 +1,96 @@
+package errcat
+
+import (
+	"context"
+	"time"
+
+	"github.com/agschwender/errcat-go/internal/errs"
+)
+
+type hedgeKey struct{}
+
+// Hedger is a policy that runs additional attempts of a call when the
+// original attempt is slow to complete, returning the first success.
+// This reduces tail latency at the cost of additional load on the
+// dependency, so it should only be used for idempotent calls.
+type Hedger struct {
+	delay     time.Duration
+	maxHedges uint
+}
+
+// NewHedger creates a new Hedger that starts another attempt each time
+// the delay passes without a success, up to the maximum number of hedged
+// attempts. It is typically declared with Caller.WithHedging, but may be
+// placed in a custom pipeline with Caller.With.
+func NewHedger(delay time.Duration, maxHedges uint) *Hedger {
+	return &Hedger{delay: delay, maxHedges: maxHedges}
+}
+
+type hedgeResult struct {
+	err   error
+	hedge uint
+}
+
+func hedgeFrom(ctx context.Context) uint {
+	hedge, _ := ctx.Value(hedgeKey{}).(uint)
+	return hedge
+}
+
+// RunContext executes the callback, starting another attempt each time
+// the delay passes without a success, up to the maximum hedges. Once an
+// attempt succeeds, the context of the remaining attempts is cancelled.
+// If every attempt fails, the error of the last to complete is
+// returned.
+func (h *Hedger) RunContext(ctx context.Context, cb func(ctx context.Context) error) error {
+	if h == nil {
+		return cb(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, h.maxHedges+1)
+	launch := func(hedge uint) {
+		go func() {
+			var err error
+			defer func() {
+				if r := recover(); r != nil {
+					err = errs.NewPanicError(r)
+				}
+				results <- hedgeResult{err: err, hedge: hedge}
+			}()
+
+			err = cb(context.WithValue(ctx, hedgeKey{}, hedge))
+		}()
+	}
+
+	timer := time.NewTimer(h.delay)
+	defer timer.Stop()
+
+	launch(0)
+	launched, completed := uint(1), uint(0)
+
+	var err error
+	for {
+		select {
+		case result := <-results:
+			completed++
+			if result.err == nil {
+				recorderFrom(ctx).won(result.hedge)
+				return nil
+			}
+			err = result.err
+			if completed == launched {
+				return err
+			}
+		case <-timer.C:
+			if launched <= h.maxHedges {
+				launch(launched)
+				launched++
+				timer.Reset(h.delay)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
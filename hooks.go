@@ -0,0 +1,284 @@
+package errcat
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+// Event describes something that happened while making a call.
+type Event struct {
+	// Attempt is the number of the attempt the event relates to,
+	// starting at one. For events that do not relate to a single
+	// attempt, it is the number of attempts made so far.
+	Attempt    uint
+	Dependency string
+	// Duration is the time taken by the attempt, the timed out policy
+	// or the call, depending on the event.
+	Duration time.Duration
+	// Error is the error that caused the event or, for the end of an
+	// attempt or call, the error it returned.
+	Error error
+	Hedge uint
+	Name  string
+}
+
+// Hooks are functions that are called as a call progresses, allowing
+// it to be logged or measured. Any of the functions may be nil. Since
+// hedged attempts run concurrently, the functions must be safe for
+// concurrent use.
+type Hooks struct {
+	// OnAttemptStart is called before each attempt is made.
+	OnAttemptStart func(Event)
+
+	// OnAttemptEnd is called after each attempt completes.
+	OnAttemptEnd func(Event)
+
+	// OnRetry is called before an attempt that follows a failed one.
+	// The event error is that of the failed attempt.
+	OnRetry func(Event)
+
+	// OnTimeout is called when a timer stops the call.
+	OnTimeout func(Event)
+
+	// OnBreakerReject is called when a breaker rejects the call.
+	OnBreakerReject func(Event)
+
+	// OnFallback is called before the fallback is used. The event
+	// error is the one that triggered the fallback.
+	OnFallback func(Event)
+
+	// OnCallComplete is called once the call, including any fallback,
+	// is complete.
+	OnCallComplete func(Event)
+}
+
+type hook uint8
+
+const (
+	onAttemptStart hook = iota
+	onAttemptEnd
+	onRetry
+	onTimeout
+	onBreakerReject
+	onFallback
+	onCallComplete
+)
+
+func (h *Hooks) get(kind hook) func(Event) {
+	switch kind {
+	case onAttemptStart:
+		return h.OnAttemptStart
+	case onAttemptEnd:
+		return h.OnAttemptEnd
+	case onRetry:
+		return h.OnRetry
+	case onTimeout:
+		return h.OnTimeout
+	case onBreakerReject:
+		return h.OnBreakerReject
+	case onFallback:
+		return h.OnFallback
+	case onCallComplete:
+		return h.OnCallComplete
+	default:
+		return nil
+	}
+}
+
+type hooksKey struct{}
+
+func withHooks(ctx context.Context, h *Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey{}, h)
+}
+
+func hooksFrom(ctx context.Context) *Hooks {
+	h, _ := ctx.Value(hooksKey{}).(*Hooks)
+	return h
+}
+
+// observer emits the events of a single call to the hooks of the
+// caller and daemon. A nil observer emits nothing, which avoids the
+// cost of tracking calls that have no hooks.
+type observer struct {
+	dependency string
+	hooks      []*Hooks
+	name       string
+	startedAt  time.Time
+
+	lock     sync.Mutex
+	attempts uint
+	failures map[uint]error
+}
+
+// observe returns the observer for a call made by the caller, or nil if
+// there are no hooks to call.
+func (c Caller) observe(ctx context.Context) *observer {
+	var hooks []*Hooks
+	if c.hooks != nil {
+		hooks = append(hooks, c.hooks)
+	}
+	if h := hooksFrom(ctx); h != nil {
+		hooks = append(hooks, h)
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	return &observer{
+		dependency: c.dependency,
+		hooks:      hooks,
+		name:       c.name,
+		startedAt:  time.Now(),
+		failures:   make(map[uint]error),
+	}
+}
+
+func (o *observer) emit(kind hook, e Event) {
+	e.Dependency = o.dependency
+	e.Name = o.name
+	for _, h := range o.hooks {
+		if fn := h.get(kind); fn != nil {
+			fn(e)
+		}
+	}
+}
+
+// attempt wraps the callback so that the start and end of each attempt
+// are emitted, along with a retry when the previous attempt of the same
+// hedge failed.
+func (o *observer) attempt(cb CallContextFn) CallContextFn {
+	if o == nil {
+		return cb
+	}
+
+	return func(ctx context.Context) error {
+		hedge := hedgeFrom(ctx)
+
+		o.lock.Lock()
+		o.attempts++
+		attempt := o.attempts
+		failure, retry := o.failures[hedge]
+		o.lock.Unlock()
+
+		if retry {
+			o.emit(onRetry, Event{Attempt: attempt, Error: failure, Hedge: hedge})
+		}
+		o.emit(onAttemptStart, Event{Attempt: attempt, Hedge: hedge})
+
+		startedAt := time.Now()
+		err := cb(ctx)
+
+		if err != nil {
+			o.lock.Lock()
+			o.failures[hedge] = err
+			o.lock.Unlock()
+		}
+		o.emit(onAttemptEnd, Event{
+			Attempt:  attempt,
+			Duration: time.Since(startedAt),
+			Error:    err,
+			Hedge:    hedge,
+		})
+		return err
+	}
+}
+
+// instrument wraps the policy if its outcome has an event, i.e. it is a
+// timer or breaker. The kind is the policy as declared, which may since
+// have been wrapped.
+func (o *observer) instrument(kind, policy Policy) Policy {
+	if o == nil {
+		return policy
+	}
+
+	switch kind.(type) {
+	case *breaker.Breaker:
+		return o.rejections(policy)
+	case *timer.Timer:
+		return o.timeouts(policy)
+	default:
+		return policy
+	}
+}
+
+// rejections emits an event when the breaker returns an error without
+// running the rest of the pipeline.
+func (o *observer) rejections(policy Policy) Policy {
+	return PolicyFn(func(ctx context.Context, next func(ctx context.Context) error) error {
+		called := false
+		err := policy.RunContext(ctx, func(ctx context.Context) error {
+			called = true
+			return next(ctx)
+		})
+		if err != nil && !called {
+			o.emit(onBreakerReject, Event{Attempt: o.attemptCount(), Error: err, Hedge: hedgeFrom(ctx)})
+		}
+		return err
+	})
+}
+
+// timeouts emits an event when the timer times out. A timeout returned
+// by a timer further down the pipeline is left to that timer to emit.
+func (o *observer) timeouts(policy Policy) Policy {
+	return PolicyFn(func(ctx context.Context, next func(ctx context.Context) error) error {
+		var lock sync.Mutex
+		inner := false
+
+		startedAt := time.Now()
+		err := policy.RunContext(ctx, func(ctx context.Context) error {
+			err := next(ctx)
+			if errors.Is(err, timer.ErrTimeout) {
+				lock.Lock()
+				inner = true
+				lock.Unlock()
+			}
+			return err
+		})
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		if errors.Is(err, timer.ErrTimeout) && !inner {
+			o.emit(onTimeout, Event{
+				Attempt:  o.attemptCount(),
+				Duration: time.Since(startedAt),
+				Error:    err,
+				Hedge:    hedgeFrom(ctx),
+			})
+		}
+		return err
+	})
+}
+
+// fallback emits the event for the fallback being triggered by the
+// error.
+func (o *observer) fallback(err error) {
+	if o == nil {
+		return
+	}
+	o.emit(onFallback, Event{Attempt: o.attemptCount(), Error: err})
+}
+
+// complete emits the event for the call completing with the error.
+func (o *observer) complete(err error) {
+	if o == nil {
+		return
+	}
+	o.emit(onCallComplete, Event{
+		Attempt:  o.attemptCount(),
+		Duration: time.Since(o.startedAt),
+		Error:    err,
+	})
+}
+
+func (o *observer) attemptCount() uint {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.attempts
+}
@@ -0,0 +1,172 @@
+package errcat_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go"
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/fallback"
+	"github.com/agschwender/errcat-go/retrier"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+type eventLog struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (l *eventLog) hooks(prefix string) errcat.Hooks {
+	record := func(name string) func(errcat.Event) {
+		return func(e errcat.Event) {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+
+			s := fmt.Sprintf("%s%s:%d", prefix, name, e.Attempt)
+			if e.Error != nil {
+				s = fmt.Sprintf("%s:%v", s, e.Error)
+			}
+			l.events = append(l.events, s)
+		}
+	}
+
+	return errcat.Hooks{
+		OnAttemptStart:  record("start"),
+		OnAttemptEnd:    record("end"),
+		OnRetry:         record("retry"),
+		OnTimeout:       record("timeout"),
+		OnBreakerReject: record("reject"),
+		OnFallback:      record("fallback"),
+		OnCallComplete:  record("complete"),
+	}
+}
+
+func (l *eventLog) get() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	events := l.events
+	l.events = nil
+	return events
+}
+
+func TestCallerWithHooks(t *testing.T) {
+	log := &eventLog{}
+
+	c := errcat.New("mysql", "users.GetUser").
+		WithHooks(log.hooks("")).
+		WithRetrier(retrier.New(retrier.WithMaxAttempts(2))).
+		WithFallback(fallback.New(func() error { return nil }))
+
+	err := c.Call(func() error { return fmt.Errorf("oops") })
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"start:1",
+		"end:1:oops",
+		"retry:2:oops",
+		"start:2",
+		"end:2:oops",
+		"fallback:2:mysql:users.GetUser: retries exhausted after 2 attempts: oops",
+		"complete:2",
+	}, log.get())
+
+	// Confirm the hooks are called when using Do
+	v, err := errcat.Do(context.Background(), c, func(context.Context) (int, error) {
+		return 1, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, []string{"start:1", "end:1", "complete:1"}, log.get())
+}
+
+func TestCallerWithHooksBreakerReject(t *testing.T) {
+	log := &eventLog{}
+
+	now := time.Now()
+	c := errcat.New("mysql", "users.GetUser").
+		WithHooks(log.hooks("")).
+		WithBreaker(breaker.New(
+			breaker.WithMaxFailures(1),
+			breaker.WithNow(func() time.Time { return now }),
+		))
+
+	c.Call(func() error { return fmt.Errorf("oops") })
+	log.get()
+
+	err := c.Call(func() error { return nil })
+	assert.ErrorIs(t, err, breaker.ErrBreakerOpen)
+	assert.Equal(t, []string{
+		"reject:0:mysql:users.GetUser: circuit breaker is open, half-open in 1m0s",
+		"complete:0:mysql:users.GetUser: circuit breaker is open, half-open in 1m0s",
+	}, log.get())
+}
+
+func TestCallerWithHooksTimeout(t *testing.T) {
+	log := &eventLog{}
+
+	// Confirm a timeout is only emitted by the timer that produced it
+	c := errcat.New("mysql", "users.GetUser").
+		WithHooks(log.hooks("")).
+		With(
+			timer.New(time.Duration(1)*time.Second),
+			timer.New(time.Duration(10)*time.Millisecond),
+		)
+
+	err := c.CallContext(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, timer.ErrTimeout)
+
+	// The timed out attempt may end at any point after the timeout, so
+	// its position among the events is not checked.
+	events := log.get()
+	require.GreaterOrEqual(t, len(events), 3)
+	assert.Equal(t, "start:1", events[0])
+	timeouts := 0
+	for _, e := range events {
+		if e == "timeout:1:mysql:users.GetUser: timeout exceeded after 10ms" {
+			timeouts++
+		}
+	}
+	assert.Equal(t, 1, timeouts)
+	assert.Contains(t, events, "complete:1:mysql:users.GetUser: timeout exceeded after 10ms")
+}
+
+func TestDaemonWithHooks(t *testing.T) {
+	log := &eventLog{}
+
+	d := errcat.NewD(errcat.WithHooks(log.hooks("daemon:")))
+
+	key, err := d.RegisterCaller(errcat.New("mysql", "users.GetUser").WithHooks(log.hooks("caller:")))
+	require.NoError(t, err)
+
+	var dependency, name string
+	otherKey, err := d.RegisterCaller(errcat.New("mysql", "users.ListUsers").WithHooks(errcat.Hooks{
+		OnCallComplete: func(e errcat.Event) {
+			dependency, name = e.Dependency, e.Name
+		},
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, d.Call(key, func() error { return nil }))
+	assert.Equal(t, []string{
+		"caller:start:1",
+		"daemon:start:1",
+		"caller:end:1",
+		"daemon:end:1",
+		"caller:complete:1",
+		"daemon:complete:1",
+	}, log.get())
+
+	require.NoError(t, d.Call(otherKey, func() error { return nil }))
+	assert.Equal(t, "mysql", dependency)
+	assert.Equal(t, "users.ListUsers", name)
+	assert.Equal(t, []string{"daemon:start:1", "daemon:end:1", "daemon:complete:1"}, log.get())
+}
@@ -0,0 +1,43 @@
+// Package errs provides the pieces shared by the errors of the various
+// policies.
+package errs
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Describe prefixes the message with the key of the caller that
+// produced the error, when it is known.
+func Describe(dependency, name, msg string) string {
+	if dependency == "" && name == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s:%s: %s", dependency, name, msg)
+}
+
+// PanicError indicates that the callback panicked. It holds the value
+// passed to panic and the stack trace of the panicking goroutine.
+type PanicError struct {
+	Dependency string
+	Name       string
+	Stack      []byte
+	Value      interface{}
+}
+
+// NewPanicError creates a PanicError for the recovered value. It must
+// be called from the deferred function that recovered the panic so
+// that the stack trace includes the panic.
+func NewPanicError(v interface{}) *PanicError {
+	return &PanicError{Stack: debug.Stack(), Value: v}
+}
+
+func (e *PanicError) Error() string {
+	return Describe(e.Dependency, e.Name, fmt.Sprintf("%v", e.Value))
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
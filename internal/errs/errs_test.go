@@ -0,0 +1,41 @@
+package errs_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/agschwender/errcat-go/internal/errs"
+)
+
+func TestDescribe(t *testing.T) {
+	assert.Equal(t, "oops", errs.Describe("", "", "oops"))
+	assert.Equal(t, "mysql:users.GetUser: oops", errs.Describe("mysql", "users.GetUser", "oops"))
+}
+
+func TestPanicError(t *testing.T) {
+	var err error
+	func() {
+		defer func() {
+			err = errs.NewPanicError(recover())
+		}()
+		panic("oops")
+	}()
+
+	var panicErr *errs.PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "oops", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestPanicError")
+	assert.Equal(t, "oops", err.Error())
+	assert.Nil(t, errors.Unwrap(err))
+
+	panicErr.Dependency, panicErr.Name = "mysql", "users.GetUser"
+	assert.Equal(t, "mysql:users.GetUser: oops", err.Error())
+
+	// Confirm a panic with an error unwraps to it
+	cause := fmt.Errorf("cause")
+	err = errs.NewPanicError(cause)
+	assert.True(t, errors.Is(err, cause))
+}
@@ -0,0 +1,97 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+// aimd increases the limit by one for each call that succeeds while the
+// limit is in use and multiplies it by the backoff ratio for each call
+// that is dropped, i.e. times out or fails.
+type aimd struct {
+	backoffRatio float64
+}
+
+func newAIMD(backoffRatio float64) *aimd {
+	if backoffRatio <= 0 || backoffRatio >= 1 {
+		backoffRatio = defaultBackoffRatio
+	}
+	return &aimd{backoffRatio: backoffRatio}
+}
+
+func (a *aimd) update(limit float64, _ time.Duration, inFlight uint, dropped bool) float64 {
+	if dropped {
+		return limit * a.backoffRatio
+	}
+
+	// The limit only grows when it is being used, otherwise a period of
+	// light traffic would let it grow without bound.
+	if float64(inFlight)*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// gradient adjusts the limit by the ratio of the long term latency to
+// the latency of recent calls, in the manner of TCP Vegas. When recent
+// calls are slower than usual, requests are queueing at the dependency
+// and the limit shrinks; otherwise it grows by a queue allowance of the
+// square root of the limit.
+type gradient struct {
+	tolerance float64
+
+	initialized bool
+	longRTT     float64
+	shortRTT    float64
+}
+
+const (
+	longRTTWeight  = 1 / 600.0
+	shortRTTWeight = 1 / 10.0
+	smoothing      = 0.2
+)
+
+func newGradient(tolerance float64) *gradient {
+	if tolerance < 1 {
+		tolerance = defaultTolerance
+	}
+	return &gradient{tolerance: tolerance}
+}
+
+func (g *gradient) update(limit float64, rtt time.Duration, inFlight uint, dropped bool) float64 {
+	// A call without a measurable latency, e.g. one served from a cache
+	// or timed by a coarse clock, says nothing about the dependency, so
+	// only its failure is taken into account.
+	if rtt <= 0 && !dropped {
+		return limit
+	}
+
+	switch {
+	case rtt <= 0:
+	case !g.initialized:
+		g.initialized = true
+		g.longRTT, g.shortRTT = float64(rtt), float64(rtt)
+	default:
+		g.longRTT += (float64(rtt) - g.longRTT) * longRTTWeight
+		g.shortRTT += (float64(rtt) - g.shortRTT) * shortRTTWeight
+	}
+
+	// The long term latency recovers quickly once the dependency is
+	// healthy again, so that it does not hold the limit down.
+	if g.longRTT/g.shortRTT > 2 {
+		g.longRTT *= 0.95
+	}
+
+	ratio := 0.5
+	if !dropped && g.shortRTT > 0 {
+		ratio = math.Max(0.5, math.Min(1, g.tolerance*g.longRTT/g.shortRTT))
+	}
+
+	next := limit*(1-smoothing) + (limit*ratio+math.Sqrt(limit))*smoothing
+
+	// Like AIMD, the limit does not grow while it is not being used.
+	if next > limit && float64(inFlight)*2 < limit {
+		return limit
+	}
+	return next
+}
@@ -0,0 +1,256 @@
+// Package limiter adapts the number of calls that may run concurrently
+// to the capacity of the dependency. Unlike a bulkhead, whose size is
+// fixed, the limit grows while the dependency is healthy and shrinks
+// when calls time out or fail.
+package limiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/agschwender/errcat-go/shadow"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+// ErrLimitExceeded indicates that the call was not made because the
+// concurrency limit has been reached.
+var ErrLimitExceeded = errors.New("concurrency limit exceeded")
+
+const (
+	defaultBackoffRatio = 0.9
+	defaultInitialLimit = uint(20)
+	defaultMaxLimit     = uint(1000)
+	defaultMinLimit     = uint(1)
+	defaultTolerance    = 1.5
+)
+
+var defaultIsFailure = func(err error) bool { return false }
+
+// algorithm determines how the limit changes as calls complete.
+// Implementations are not safe for concurrent use and rely on the
+// limiter's lock.
+type algorithm interface {
+	// update returns the new limit given the latency of a call, the
+	// number of calls in flight when it was made and whether it was
+	// dropped.
+	update(limit float64, rtt time.Duration, inFlight uint, dropped bool) float64
+}
+
+// Limiter limits the number of calls that may run concurrently, adapting
+// the limit to the latencies and failures of the calls it observes.
+// Calls beyond the limit are rejected. A limiter may be shared by
+// multiple callers that access the same dependency.
+type Limiter struct {
+	algorithm  algorithm
+	isFailure  func(err error) bool
+	maxLimit   uint
+	minLimit   uint
+	now        func() time.Time
+	shadowMode bool
+
+	lock     sync.Mutex
+	inFlight uint
+	limit    float64
+}
+
+type option func(*Limiter)
+
+// NewAIMD creates a new Limiter that grows the limit by one for each
+// successful call and multiplies it by the backoff ratio, between 0 and
+// 1, for each dropped call. A ratio of zero uses 0.9.
+func NewAIMD(backoffRatio float64, opts ...option) *Limiter {
+	return newLimiter(newAIMD(backoffRatio), opts...)
+}
+
+// NewGradient creates a new Limiter that adjusts the limit by comparing
+// the latency of recent calls to the long term latency, shrinking it as
+// calls slow down. The tolerance is how much slower, as a ratio of at
+// least 1, recent calls may be before the limit shrinks. A tolerance of
+// zero uses 1.5.
+func NewGradient(tolerance float64, opts ...option) *Limiter {
+	return newLimiter(newGradient(tolerance), opts...)
+}
+
+func newLimiter(a algorithm, opts ...option) *Limiter {
+	l := &Limiter{
+		algorithm: a,
+		isFailure: defaultIsFailure,
+		limit:     float64(defaultInitialLimit),
+		maxLimit:  defaultMaxLimit,
+		minLimit:  defaultMinLimit,
+		now:       time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	l.limit = l.clamp(l.limit)
+
+	return l
+}
+
+// WithInitialLimit sets the limit before any calls are observed.
+func WithInitialLimit(limit uint) option {
+	return func(l *Limiter) {
+		if limit == 0 {
+			limit = defaultInitialLimit
+		}
+		l.limit = float64(limit)
+	}
+}
+
+// WithIsFailure defines the errors, besides timeouts, that indicate the
+// dependency is overloaded and should shrink the limit. By default,
+// only timeouts shrink the limit.
+func WithIsFailure(isFailure func(err error) bool) option {
+	return func(l *Limiter) {
+		if isFailure == nil {
+			isFailure = defaultIsFailure
+		}
+		l.isFailure = isFailure
+	}
+}
+
+// WithMaxLimit sets the maximum the limit may grow to.
+func WithMaxLimit(limit uint) option {
+	return func(l *Limiter) {
+		if limit == 0 {
+			limit = defaultMaxLimit
+		}
+		l.maxLimit = limit
+	}
+}
+
+// WithMinLimit sets the minimum the limit may shrink to.
+func WithMinLimit(limit uint) option {
+	return func(l *Limiter) {
+		if limit == 0 {
+			limit = defaultMinLimit
+		}
+		l.minLimit = limit
+	}
+}
+
+// WithNow sets the function for getting the current time. This is only
+// useful for testing.
+func WithNow(now func() time.Time) option {
+	return func(l *Limiter) {
+		if now == nil {
+			now = time.Now
+		}
+		l.now = now
+	}
+}
+
+// WithShadowMode indicates whether the limiter should run in shadow
+// mode, in which its limit adapts as usual but calls are never
+// rejected. Calls beyond the limit are run regardless and reported
+// using the shadow package instead.
+func WithShadowMode(enabled bool) option {
+	return func(l *Limiter) {
+		l.shadowMode = enabled
+	}
+}
+
+// Run executes the callback if the limit has not been reached.
+func (l *Limiter) Run(cb func() error) error {
+	return l.RunContext(context.Background(), func(context.Context) error {
+		return cb()
+	})
+}
+
+// RunContext executes the callback if the limit has not been reached,
+// then adapts the limit to the latency and result of the callback.
+func (l *Limiter) RunContext(ctx context.Context, cb func(ctx context.Context) error) error {
+	if l == nil {
+		return cb(ctx)
+	}
+
+	inFlight, admitted, exceeded := l.acquire()
+	if !admitted {
+		return ErrLimitExceeded
+	}
+	if exceeded {
+		shadow.Report(ctx, ErrLimitExceeded)
+	}
+
+	// The slot is released even if the callback panics, in which case
+	// the call is not taken to suggest the dependency is overloaded.
+	startedAt, dropped := l.now(), false
+	defer func() {
+		l.release(l.now().Sub(startedAt), inFlight, dropped)
+	}()
+
+	err := cb(ctx)
+	dropped = l.dropped(err)
+	return err
+}
+
+// Limit returns the current concurrency limit.
+func (l *Limiter) Limit() uint {
+	if l == nil {
+		return 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return uint(l.limit)
+}
+
+// InFlight returns the number of calls currently running.
+func (l *Limiter) InFlight() uint {
+	if l == nil {
+		return 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.inFlight
+}
+
+// acquire takes a slot for the call, returning the number of calls in
+// flight once admitted. Calls beyond the limit are rejected unless the
+// limiter is in shadow mode, in which case they are admitted but
+// reported as having exceeded it.
+func (l *Limiter) acquire() (inFlight uint, admitted, exceeded bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	exceeded = l.inFlight >= uint(l.limit)
+	if exceeded && !l.shadowMode {
+		return l.inFlight, false, true
+	}
+	l.inFlight++
+	return l.inFlight, true, exceeded
+}
+
+func (l *Limiter) release(rtt time.Duration, inFlight uint, dropped bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.inFlight--
+	l.limit = l.clamp(l.algorithm.update(l.limit, rtt, inFlight, dropped))
+}
+
+// dropped indicates whether the call suggests the dependency is
+// overloaded. Calls abandoned by the caller are not counted, since they
+// say nothing about the dependency.
+func (l *Limiter) dropped(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, timer.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	return l.isFailure(err)
+}
+
+func (l *Limiter) clamp(limit float64) float64 {
+	return math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), limit))
+}
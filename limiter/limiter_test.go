@@ -0,0 +1,223 @@
+package limiter_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go/limiter"
+	"github.com/agschwender/errcat-go/shadow"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// hold runs n calls that remain in flight until released.
+func hold(l *limiter.Limiter, n int) (release func()) {
+	ch := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		started := make(chan struct{})
+		go func() {
+			defer wg.Done()
+			l.Run(func() error {
+				close(started)
+				<-ch
+				return nil
+			})
+		}()
+		<-started
+	}
+
+	return func() {
+		close(ch)
+		wg.Wait()
+	}
+}
+
+func TestAsNil(t *testing.T) {
+	var l *limiter.Limiter
+
+	assert.NoError(t, l.Run(func() error { return nil }))
+	assert.Equal(t, uint(0), l.Limit())
+	assert.Equal(t, uint(0), l.InFlight())
+}
+
+func TestWithDefaults(t *testing.T) {
+	l := limiter.NewAIMD(0)
+	assert.Equal(t, uint(20), l.Limit())
+	assert.Equal(t, uint(0), l.InFlight())
+}
+
+func TestRejectsOverLimit(t *testing.T) {
+	l := limiter.NewAIMD(0, limiter.WithInitialLimit(2))
+
+	release := hold(l, 2)
+	assert.Equal(t, uint(2), l.InFlight())
+	err := l.Run(func() error { return nil })
+	assert.ErrorIs(t, err, limiter.ErrLimitExceeded)
+
+	release()
+	assert.Equal(t, uint(0), l.InFlight())
+}
+
+func TestWithPanic(t *testing.T) {
+	l := limiter.NewAIMD(0, limiter.WithInitialLimit(1))
+
+	// Confirm the slot of a call that panics is released
+	assert.Panics(t, func() {
+		l.Run(func() error { panic("oops") })
+	})
+	assert.Equal(t, uint(0), l.InFlight())
+	assert.NoError(t, l.Run(func() error { return nil }))
+}
+
+func TestAIMD(t *testing.T) {
+	l := limiter.NewAIMD(0.5,
+		limiter.WithInitialLimit(4),
+		limiter.WithMaxLimit(5),
+		limiter.WithMinLimit(1),
+	)
+
+	// Confirm the limit grows with successful calls while in use, up to
+	// the maximum
+	for i := 0; i < 5; i++ {
+		l.Run(func() error { return nil })
+	}
+	assert.Equal(t, uint(4), l.Limit(), "the limit should not grow while unused")
+
+	release := hold(l, 2)
+	for i := 0; i < 3; i++ {
+		l.Run(func() error { return nil })
+	}
+	release()
+	assert.Equal(t, uint(5), l.Limit())
+
+	// Confirm timeouts shrink the limit, down to the minimum
+	l.Run(func() error { return &timer.TimeoutError{} })
+	assert.Equal(t, uint(2), l.Limit())
+	l.Run(func() error { return context.DeadlineExceeded })
+	l.Run(func() error { return context.DeadlineExceeded })
+	assert.Equal(t, uint(1), l.Limit())
+
+	// Confirm other errors only shrink the limit when failures
+	l = limiter.NewAIMD(0.5, limiter.WithInitialLimit(10))
+	l.Run(func() error { return fmt.Errorf("not found") })
+	l.Run(func() error { return context.Canceled })
+	assert.Equal(t, uint(10), l.Limit())
+
+	errOverloaded := fmt.Errorf("overloaded")
+	l = limiter.NewAIMD(0.5,
+		limiter.WithInitialLimit(10),
+		limiter.WithIsFailure(func(err error) bool { return err == errOverloaded }),
+	)
+	l.Run(func() error { return errOverloaded })
+	assert.Equal(t, uint(5), l.Limit())
+}
+
+func TestGradient(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := limiter.NewGradient(0,
+		limiter.WithInitialLimit(20),
+		limiter.WithMaxLimit(100),
+		limiter.WithNow(clock.Now),
+	)
+
+	call := func(latency time.Duration) {
+		l.Run(func() error {
+			clock.Add(latency)
+			return nil
+		})
+	}
+
+	// Confirm the limit grows while the latency is steady and the limit
+	// is in use
+	release := hold(l, 10)
+	for i := 0; i < 20; i++ {
+		call(time.Duration(10) * time.Millisecond)
+	}
+	grown := l.Limit()
+	assert.Greater(t, grown, uint(20))
+
+	// Confirm the limit shrinks once calls slow down
+	for i := 0; i < 20; i++ {
+		call(time.Duration(100) * time.Millisecond)
+	}
+	assert.Less(t, l.Limit(), grown)
+	release()
+}
+
+func TestGradientWithoutLatency(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := limiter.NewGradient(0,
+		limiter.WithInitialLimit(20),
+		limiter.WithMaxLimit(100),
+		limiter.WithNow(clock.Now),
+	)
+
+	release := hold(l, 10)
+	defer release()
+
+	// Confirm calls without a measurable latency do not shrink the limit
+	for i := 0; i < 20; i++ {
+		l.Run(func() error { return nil })
+	}
+	assert.Equal(t, uint(20), l.Limit())
+
+	// Confirm the latency is tracked from the first measurable call
+	for i := 0; i < 20; i++ {
+		l.Run(func() error {
+			clock.Add(time.Duration(10) * time.Millisecond)
+			return nil
+		})
+	}
+	assert.Greater(t, l.Limit(), uint(20))
+}
+
+func TestWithShadowMode(t *testing.T) {
+	l := limiter.NewAIMD(0,
+		limiter.WithInitialLimit(2),
+		limiter.WithShadowMode(true),
+	)
+
+	release := hold(l, 2)
+	defer release()
+
+	var reported []error
+	ctx := shadow.NewContext(context.Background(), func(err error) {
+		reported = append(reported, err)
+	})
+
+	// Confirm the call is made, rather than rejected, and the rejection
+	// is reported
+	called := false
+	err := l.RunContext(ctx, func(context.Context) error {
+		called = true
+		assert.Equal(t, uint(3), l.InFlight())
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, called)
+	assert.Equal(t, []error{limiter.ErrLimitExceeded}, reported)
+}
@@ -0,0 +1,51 @@
+package errcat
+
+import (
+	"context"
+
+	"github.com/agschwender/errcat-go/breaker"
+	"github.com/agschwender/errcat-go/bulkhead"
+	"github.com/agschwender/errcat-go/cache"
+	"github.com/agschwender/errcat-go/limiter"
+	"github.com/agschwender/errcat-go/ratelimit"
+	"github.com/agschwender/errcat-go/retrier"
+	"github.com/agschwender/errcat-go/timer"
+)
+
+// Policy controls how a call is made. A policy receives the next step
+// of the pipeline and decides whether, when and how many times to run
+// it, returning the resulting error.
+type Policy interface {
+	RunContext(ctx context.Context, next func(ctx context.Context) error) error
+}
+
+// PolicyFn allows a function to be used as a Policy.
+type PolicyFn func(ctx context.Context, next func(ctx context.Context) error) error
+
+// RunContext calls the function with the next step of the pipeline.
+func (fn PolicyFn) RunContext(ctx context.Context, next func(ctx context.Context) error) error {
+	return fn(ctx, next)
+}
+
+var (
+	_ Policy = (*breaker.Breaker)(nil)
+	_ Policy = (*bulkhead.Bulkhead)(nil)
+	_ Policy = (*cache.Cache)(nil)
+	_ Policy = (*Hedger)(nil)
+	_ Policy = (*limiter.Limiter)(nil)
+	_ Policy = (*ratelimit.RateLimiter)(nil)
+	_ Policy = (*retrier.Retrier)(nil)
+	_ Policy = (*timer.Timer)(nil)
+)
+
+// compose nests the policies around the callback, such that the first
+// policy is the outermost.
+func compose(policies []Policy, cb CallContextFn) CallContextFn {
+	for i := len(policies) - 1; i >= 0; i-- {
+		policy, next := policies[i], cb
+		cb = func(ctx context.Context) error {
+			return policy.RunContext(ctx, next)
+		}
+	}
+	return cb
+}
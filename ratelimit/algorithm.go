@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// tokenBucket refills tokens at a constant rate up to the burst size,
+// with each call consuming a token. A call that must wait reserves a
+// future token by driving the balance negative.
+type tokenBucket struct {
+	burst    float64
+	rate     float64
+	tokens   float64
+	updateAt time.Time
+}
+
+func newTokenBucket(rate float64, burst uint) *tokenBucket {
+	if burst == 0 {
+		burst = 1
+	}
+	return &tokenBucket{
+		burst:  float64(burst),
+		rate:   rate,
+		tokens: float64(burst),
+	}
+}
+
+func (b *tokenBucket) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	if b.rate <= 0 {
+		return 0, false
+	}
+
+	seconds := (1 - b.tokens) / b.rate
+	if seconds > float64(maxWait)/float64(time.Second) {
+		return 0, false
+	}
+
+	b.tokens--
+	return time.Duration(math.Ceil(seconds * float64(time.Second))), true
+}
+
+func (b *tokenBucket) cancel(time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.updateAt.IsZero() && now.After(b.updateAt) {
+		elapsed := now.Sub(b.updateAt).Seconds()
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	}
+	if now.After(b.updateAt) {
+		b.updateAt = now
+	}
+}
+
+// slidingLog records the time of each call, allowing a call only when
+// fewer than limit calls were made within the preceding window. A call
+// that must wait is recorded at the future time it will be made.
+type slidingLog struct {
+	limit  int
+	log    []time.Time
+	window time.Duration
+}
+
+func newSlidingLog(limit uint, window time.Duration) *slidingLog {
+	if limit == 0 {
+		limit = 1
+	}
+	return &slidingLog{
+		limit:  int(limit),
+		log:    make([]time.Time, 0, limit),
+		window: window,
+	}
+}
+
+func (l *slidingLog) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	l.evict(now)
+
+	if len(l.log) < l.limit {
+		l.log = append(l.log, now)
+		return 0, true
+	}
+
+	// The call can be made once the entry limit places back leaves the
+	// window.
+	at := l.log[len(l.log)-l.limit].Add(l.window)
+	d := at.Sub(now)
+	if d > maxWait {
+		return 0, false
+	}
+
+	l.log = append(l.log, at)
+	return d, true
+}
+
+func (l *slidingLog) cancel(at time.Time) {
+	for i := len(l.log) - 1; i >= 0; i-- {
+		if l.log[i].Equal(at) {
+			l.log = append(l.log[:i], l.log[i+1:]...)
+			return
+		}
+	}
+}
+
+func (l *slidingLog) evict(now time.Time) {
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(l.log) && !l.log[i].After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		l.log = append(l.log[:0], l.log[i:]...)
+	}
+}
@@ -0,0 +1,239 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/agschwender/errcat-go/shadow"
+)
+
+// ErrRateLimited indicates that the call was not made because it would
+// exceed the rate limit.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+type Mode uint8
+
+func (m Mode) String() string {
+	switch m {
+	case FailFast:
+		return "fail-fast"
+	case Wait:
+		return "wait"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// FailFast indicates a call that exceeds the rate limit is rejected
+	// immediately.
+	FailFast Mode = 0
+
+	// Wait indicates a call that exceeds the rate limit will wait until
+	// it can be made, as long as the context and maximum wait allow.
+	Wait Mode = 1
+)
+
+// algorithm determines when calls may be made. Implementations are not
+// safe for concurrent use and rely on the rate limiter's lock.
+type algorithm interface {
+	// reserve returns how long the call must wait before it can be
+	// made. If that exceeds the maximum wait, no reservation is made
+	// and false is returned.
+	reserve(now time.Time, maxWait time.Duration) (time.Duration, bool)
+
+	// cancel releases a reservation that will not be used.
+	cancel(at time.Time)
+}
+
+// RateLimiter limits the rate at which calls are made. A rate limiter
+// may be shared by multiple callers that access the same dependency so
+// that they are limited as a whole.
+type RateLimiter struct {
+	algorithm  algorithm
+	maxWait    time.Duration
+	mode       Mode
+	now        func() time.Time
+	shadowMode bool
+	sleep      func(ctx context.Context, d time.Duration) error
+
+	lock sync.Mutex
+}
+
+type option func(*RateLimiter)
+
+// NewTokenBucket creates a new RateLimiter that allows calls at the
+// supplied rate per second, with bursts of up to burst calls.
+func NewTokenBucket(rate float64, burst uint, opts ...option) *RateLimiter {
+	return newRateLimiter(newTokenBucket(rate, burst), opts...)
+}
+
+// NewSlidingLog creates a new RateLimiter that allows at most limit
+// calls within any period of the supplied duration. Unlike a token
+// bucket, this strictly enforces the limit, at the cost of remembering
+// the time of each call in the window.
+func NewSlidingLog(limit uint, window time.Duration, opts ...option) *RateLimiter {
+	return newRateLimiter(newSlidingLog(limit, window), opts...)
+}
+
+func newRateLimiter(a algorithm, opts ...option) *RateLimiter {
+	l := &RateLimiter{
+		algorithm: a,
+		mode:      FailFast,
+		now:       time.Now,
+		sleep:     sleep,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// WithMaxWait sets the maximum duration a call will wait when in the
+// Wait mode. By default, calls wait as long as their context allows.
+func WithMaxWait(maxWait time.Duration) option {
+	return func(l *RateLimiter) {
+		l.maxWait = maxWait
+	}
+}
+
+// WithMode sets whether calls that exceed the rate limit should fail
+// fast or wait.
+func WithMode(mode Mode) option {
+	return func(l *RateLimiter) {
+		l.mode = mode
+	}
+}
+
+// WithNow sets the function for getting the current time. This is only
+// useful for testing.
+func WithNow(now func() time.Time) option {
+	return func(l *RateLimiter) {
+		if now == nil {
+			now = time.Now
+		}
+		l.now = now
+	}
+}
+
+// WithShadowMode indicates whether the rate limiter should run in
+// shadow mode, in which calls never wait or are rejected. Calls consume
+// from the rate limit as they would otherwise, so those that would have
+// waited are accounted for without waiting, while those that would have
+// been rejected consume nothing and are reported using the shadow
+// package instead.
+func WithShadowMode(enabled bool) option {
+	return func(l *RateLimiter) {
+		l.shadowMode = enabled
+	}
+}
+
+// WithSleep sets the function used to wait for the rate limit. The
+// function must return the context's error if it is done before the
+// duration has passed. This is only useful for testing.
+func WithSleep(fn func(ctx context.Context, d time.Duration) error) option {
+	return func(l *RateLimiter) {
+		if fn == nil {
+			fn = sleep
+		}
+		l.sleep = fn
+	}
+}
+
+// Run executes the callback if it does not exceed the rate limit.
+func (l *RateLimiter) Run(cb func() error) error {
+	return l.RunContext(context.Background(), func(context.Context) error {
+		return cb()
+	})
+}
+
+// RunContext executes the callback if it does not exceed the rate
+// limit. When in the Wait mode, it will first wait until the callback
+// can be run. If the context would be done before then, the call is
+// rejected immediately rather than waiting in vain.
+func (l *RateLimiter) RunContext(ctx context.Context, cb func(ctx context.Context) error) error {
+	if l == nil {
+		return cb(ctx)
+	}
+
+	if err := l.wait(ctx); err != nil {
+		if !l.shadowMode || !errors.Is(err, ErrRateLimited) {
+			return err
+		}
+		shadow.Report(ctx, err)
+	}
+	return cb(ctx)
+}
+
+// Allow indicates whether a call can be made now, reserving it if so.
+// This is useful for those cases where the call is not made with Run.
+func (l *RateLimiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	_, ok := l.algorithm.reserve(l.now(), 0)
+	return ok
+}
+
+func (l *RateLimiter) wait(ctx context.Context) error {
+	l.lock.Lock()
+	now := l.now()
+	d, ok := l.algorithm.reserve(now, l.maxWaitFor(ctx, now))
+	l.lock.Unlock()
+
+	if !ok {
+		return ErrRateLimited
+	}
+	if d <= 0 || l.shadowMode {
+		return nil
+	}
+
+	if err := l.sleep(ctx, d); err != nil {
+		l.lock.Lock()
+		l.algorithm.cancel(now.Add(d))
+		l.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// maxWaitFor returns the maximum duration the call may wait, which is
+// limited by both the configured maximum wait and the context deadline.
+func (l *RateLimiter) maxWaitFor(ctx context.Context, now time.Time) time.Duration {
+	if l.mode != Wait {
+		return 0
+	}
+
+	maxWait := time.Duration(math.MaxInt64)
+	if l.maxWait > 0 {
+		maxWait = l.maxWait
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Sub(now) < maxWait {
+		maxWait = deadline.Sub(now)
+	}
+	if maxWait < 0 {
+		maxWait = 0
+	}
+	return maxWait
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,225 @@
+package ratelimit_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go/ratelimit"
+	"github.com/agschwender/errcat-go/shadow"
+)
+
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+func run(l *ratelimit.RateLimiter) error {
+	return l.Run(func() error { return nil })
+}
+
+func TestAsNil(t *testing.T) {
+	var l *ratelimit.RateLimiter
+	assert.True(t, l.Allow())
+
+	counts := 0
+	err := l.Run(func() error {
+		counts++
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, counts)
+}
+
+func TestModeString(t *testing.T) {
+	assert.Equal(t, "fail-fast", ratelimit.FailFast.String())
+	assert.Equal(t, "wait", ratelimit.Wait.String())
+	assert.Equal(t, "unknown", ratelimit.Mode(100).String())
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := ratelimit.NewTokenBucket(10, 2, ratelimit.WithNow(clock.Now))
+
+	// The burst is available immediately
+	err := l.Run(func() error { return fmt.Errorf("oops") })
+	require.Error(t, err)
+	assert.Equal(t, "oops", err.Error())
+	require.NoError(t, run(l))
+	assert.Equal(t, ratelimit.ErrRateLimited, run(l))
+
+	// Tokens refill at the rate
+	clock.now = clock.now.Add(time.Duration(100) * time.Millisecond)
+	require.NoError(t, run(l))
+	assert.Equal(t, ratelimit.ErrRateLimited, run(l))
+
+	// Tokens do not exceed the burst
+	clock.now = clock.now.Add(time.Second)
+	assert.True(t, l.Allow())
+	assert.True(t, l.Allow())
+	assert.False(t, l.Allow())
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := ratelimit.NewTokenBucket(10, 1,
+		ratelimit.WithMode(ratelimit.Wait),
+		ratelimit.WithNow(clock.Now),
+		ratelimit.WithSleep(clock.Sleep),
+	)
+
+	// Calls wait for their token rather than failing
+	for i := 0; i < 3; i++ {
+		require.NoError(t, run(l))
+	}
+	assert.Equal(t, []time.Duration{
+		time.Duration(100) * time.Millisecond,
+		time.Duration(100) * time.Millisecond,
+	}, clock.sleeps)
+}
+
+func TestSlidingLog(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := ratelimit.NewSlidingLog(3, time.Second, ratelimit.WithNow(clock.Now))
+
+	require.NoError(t, run(l))
+	clock.now = clock.now.Add(time.Duration(500) * time.Millisecond)
+	require.NoError(t, run(l))
+	require.NoError(t, run(l))
+	assert.Equal(t, ratelimit.ErrRateLimited, run(l))
+
+	// Only the first call has left the window
+	clock.now = clock.now.Add(time.Duration(500) * time.Millisecond)
+	require.NoError(t, run(l))
+	assert.Equal(t, ratelimit.ErrRateLimited, run(l))
+
+	clock.now = clock.now.Add(time.Duration(500) * time.Millisecond)
+	assert.True(t, l.Allow())
+	assert.True(t, l.Allow())
+	assert.False(t, l.Allow())
+}
+
+func TestSlidingLogWait(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := ratelimit.NewSlidingLog(2, time.Second,
+		ratelimit.WithMode(ratelimit.Wait),
+		ratelimit.WithMaxWait(time.Duration(1500)*time.Millisecond),
+		ratelimit.WithNow(clock.Now),
+		ratelimit.WithSleep(clock.Sleep),
+	)
+
+	require.NoError(t, run(l))
+	require.NoError(t, run(l))
+	require.NoError(t, run(l))
+	assert.Equal(t, []time.Duration{time.Second}, clock.sleeps)
+}
+
+func TestWithMaxWait(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := ratelimit.NewTokenBucket(1, 1,
+		ratelimit.WithMode(ratelimit.Wait),
+		ratelimit.WithMaxWait(time.Duration(500)*time.Millisecond),
+		ratelimit.WithNow(clock.Now),
+		ratelimit.WithSleep(clock.Sleep),
+	)
+
+	require.NoError(t, run(l))
+	assert.Equal(t, ratelimit.ErrRateLimited, run(l))
+	assert.Empty(t, clock.sleeps)
+}
+
+func TestWaitWithContextDeadline(t *testing.T) {
+	l := ratelimit.NewTokenBucket(1, 1, ratelimit.WithMode(ratelimit.Wait))
+	require.NoError(t, run(l))
+
+	// The call fails immediately since the context would be done
+	// before the token is available.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(10)*time.Millisecond)
+	defer cancel()
+	err := l.RunContext(ctx, func(ctx context.Context) error { return nil })
+	assert.Equal(t, ratelimit.ErrRateLimited, err)
+}
+
+func TestWaitCancelled(t *testing.T) {
+	l := ratelimit.NewSlidingLog(1, time.Duration(50)*time.Millisecond, ratelimit.WithMode(ratelimit.Wait))
+	require.NoError(t, run(l))
+
+	// A cancelled wait releases its reservation
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Duration(10) * time.Millisecond)
+		cancel()
+	}()
+	err := l.RunContext(ctx, func(ctx context.Context) error { return nil })
+	assert.Equal(t, context.Canceled, err)
+
+	time.Sleep(time.Duration(50) * time.Millisecond)
+	assert.True(t, l.Allow())
+}
+
+func TestWithZeroValues(t *testing.T) {
+	l := ratelimit.NewTokenBucket(0, 0, ratelimit.WithNow(nil), ratelimit.WithSleep(nil))
+	require.NoError(t, run(l))
+	assert.Equal(t, ratelimit.ErrRateLimited, run(l))
+
+	l = ratelimit.NewSlidingLog(0, time.Second)
+	require.NoError(t, run(l))
+	assert.Equal(t, ratelimit.ErrRateLimited, run(l))
+}
+
+func TestWithShadowMode(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := ratelimit.NewTokenBucket(10, 1,
+		ratelimit.WithNow(clock.Now),
+		ratelimit.WithShadowMode(true),
+	)
+
+	var reported []error
+	ctx := shadow.NewContext(context.Background(), func(err error) {
+		reported = append(reported, err)
+	})
+
+	// Confirm calls beyond the rate are made, rather than rejected, and
+	// the rejections are reported
+	called := 0
+	for i := 0; i < 3; i++ {
+		err := l.RunContext(ctx, func(context.Context) error {
+			called++
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, called)
+	assert.Equal(t, []error{ratelimit.ErrRateLimited, ratelimit.ErrRateLimited}, reported)
+
+	// Confirm the calls that would have been rejected did not consume
+	// from the rate limit
+	reported = nil
+	clock.now = clock.now.Add(time.Duration(100) * time.Millisecond)
+	require.NoError(t, l.RunContext(ctx, func(context.Context) error { return nil }))
+	assert.Empty(t, reported)
+
+	// Confirm calls do not wait for the rate limit
+	l = ratelimit.NewTokenBucket(10, 1,
+		ratelimit.WithMode(ratelimit.Wait),
+		ratelimit.WithNow(clock.Now),
+		ratelimit.WithShadowMode(true),
+		ratelimit.WithSleep(clock.Sleep),
+	)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, run(l))
+	}
+	assert.Empty(t, clock.sleeps)
+}
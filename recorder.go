@@ -0,0 +1,201 @@
+package errcat
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	errcatapi "github.com/agschwender/errcat-go/api"
+	"github.com/agschwender/errcat-go/shadow"
+)
+
+type recorderKey struct{}
+
+// recorder collects the attempts made while running a call. It is
+// carried by the context so that it captures attempts made by any of
+// the caller's policies, including those made concurrently by hedging.
+type recorder struct {
+	lock     sync.Mutex
+	attempts []errcatapi.Attempt
+	cached   bool
+	coalesce uint
+	done     bool
+	fallback string
+	fault    string
+	hedge    uint
+	rejected []error
+	shared   bool
+}
+
+// withRecorder returns a copy of the context carrying the recorder,
+// which also collects the rejections reported by policies in shadow
+// mode. A nil recorder stops the call from being recorded.
+func withRecorder(ctx context.Context, r *recorder) context.Context {
+	var report func(err error)
+	if r != nil {
+		report = r.wouldReject
+	}
+	return context.WithValue(shadow.NewContext(ctx, report), recorderKey{}, r)
+}
+
+func recorderFrom(ctx context.Context) *recorder {
+	r, _ := ctx.Value(recorderKey{}).(*recorder)
+	return r
+}
+
+// attempt records an attempt. Attempts that complete after the call has
+// finished, e.g. because of a timeout, are discarded.
+func (r *recorder) attempt(a errcatapi.Attempt) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.done {
+		r.attempts = append(r.attempts, a)
+	}
+}
+
+// won records the hedge that produced the result of the call.
+func (r *recorder) won(hedge uint) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.done {
+		r.hedge = hedge
+	}
+}
+
+// fellBack records the fallback tier that produced the result of the
+// call.
+func (r *recorder) fellBack(tier string) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.done {
+		r.fallback = tier
+	}
+}
+
+// hit records that the result of the call was served by the cache.
+func (r *recorder) hit() {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.done {
+		r.cached = true
+	}
+}
+
+// coalesced records the number of calls that waited on the result of
+// the call rather than making their own.
+func (r *recorder) coalesced(waiters uint) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.done {
+		r.coalesce = waiters
+	}
+}
+
+// waited records that the call shared the result of another call with
+// the same key, rather than calling the dependency itself.
+func (r *recorder) waited() {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.shared = true
+}
+
+// injected records the fault injected into an attempt of the call. The
+// first fault injected is kept when several attempts are affected.
+func (r *recorder) injected(fault string) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.done && r.fault == "" {
+		r.fault = fault
+	}
+}
+
+// wouldReject records the rejection a policy in shadow mode would have
+// made.
+func (r *recorder) wouldReject(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.done {
+		r.rejected = append(r.rejected, err)
+	}
+}
+
+// rejections returns the rejections policies in shadow mode would have
+// made.
+func (r *recorder) rejections() []error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.rejected
+}
+
+// finish stops recording and records the attempts, whether the result
+// was cached, the coalesced calls, fallback tier, injected fault and
+// winning hedge on the call. It returns false when
+// the call shared the result of another, since that call is recorded
+// in its place.
+func (r *recorder) finish(call *errcatapi.Call) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.done = true
+	call.Attempts, call.Cached, call.Coalesced = r.attempts, r.cached, r.coalesce
+	call.Fallback, call.Fault, call.Hedge = r.fallback, r.fault, r.hedge
+	return !r.shared
+}
+
+// recordAttempts wraps the callback so that each time it is run is
+// recorded as an attempt.
+func recordAttempts(cb CallContextFn) CallContextFn {
+	return func(ctx context.Context) error {
+		r := recorderFrom(ctx)
+		if r == nil {
+			return cb(ctx)
+		}
+
+		startedAt := time.Now()
+		err := cb(ctx)
+		r.attempt(errcatapi.Attempt{
+			Duration:  time.Since(startedAt),
+			Error:     err,
+			Hedge:     hedgeFrom(ctx),
+			StartedAt: startedAt,
+		})
+		return err
+	}
+}
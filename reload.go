@@ -0,0 +1,183 @@
+package errcat
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+const defaultReloadInterval = time.Duration(30) * time.Second
+
+// ConfigSource supplies the callers the daemon should use. The daemon
+// polls the source, so that the policies of its callers may be changed
+// without restarting the service. Callers reports whether they have
+// changed since the previous call, which allows the daemon to skip
+// reloads when nothing has changed.
+//
+// A source that wishes to preserve the state of its breakers across
+// reloads should reuse the breakers of the callers it previously
+// returned, reconfiguring them when its callers are committed. See
+// config.FileSource for an example.
+type ConfigSource interface {
+	Callers(ctx context.Context) (callers []Caller, changed bool, err error)
+}
+
+// ConfigCommitter may be implemented by a ConfigSource whose callers
+// share state with the callers in use, such as breakers that are
+// reconfigured rather than replaced. Commit is called once the callers
+// of the last call to Callers are known to replace those in use, so
+// that the shared state is only changed when the reload succeeds. When
+// Commit fails, the reload fails and the previous callers remain in
+// use.
+type ConfigCommitter interface {
+	Commit() error
+}
+
+// ReloadEvent describes a reload of the callers supplied by the config
+// source, identifying the callers by key. When the reload fails, Err is
+// set and the previous callers remain in use.
+type ReloadEvent struct {
+	Added   []string
+	Updated []string
+	Removed []string
+	Err     error
+}
+
+// WithConfigSource defines the source of callers that the daemon polls
+// at the interval once started. An interval of zero polls every 30
+// seconds. The callers of the source are registered alongside those
+// registered in code, but may not share their keys.
+func WithConfigSource(src ConfigSource, interval time.Duration) optionD {
+	return func(d *Daemon) {
+		if interval <= 0 {
+			interval = defaultReloadInterval
+		}
+		d.source = src
+		d.reloadInterval = interval
+	}
+}
+
+// WithReloadHook defines the function called after each reload of the
+// config source, including those that fail.
+func WithReloadHook(fn func(ReloadEvent)) optionD {
+	return func(d *Daemon) {
+		d.onReload = fn
+	}
+}
+
+// Reload loads the callers of the config source and swaps them in for
+// those of the previous load. Callers that are no longer supplied by the
+// source are removed, while callers registered in code are untouched.
+// The daemon reloads automatically once started, so this is only needed
+// to force a reload. Concurrent reloads are run one at a time.
+func (d *Daemon) Reload(ctx context.Context) error {
+	if d == nil || d.source == nil {
+		return nil
+	}
+
+	d.reloadLock.Lock()
+	defer d.reloadLock.Unlock()
+
+	callers, changed, err := d.source.Callers(ctx)
+	if err == nil && !changed {
+		return nil
+	}
+
+	var event ReloadEvent
+	if err == nil {
+		event, err = d.swap(callers, d.commit)
+	}
+	if err != nil {
+		event = ReloadEvent{Err: fmt.Errorf("reload failed: %w", err)}
+		log.Printf("%v", event.Err)
+	} else {
+		log.Printf(
+			"reloaded callers: %d added, %d updated, %d removed",
+			len(event.Added),
+			len(event.Updated),
+			len(event.Removed),
+		)
+	}
+
+	if d.onReload != nil {
+		d.onReload(event)
+	}
+	return event.Err
+}
+
+// commit commits the callers of the config source, if it requires it.
+func (d *Daemon) commit() error {
+	if c, ok := d.source.(ConfigCommitter); ok {
+		return c.Commit()
+	}
+	return nil
+}
+
+// swap replaces the callers of the previous load with the callers in a
+// single step, so that calls never observe a partial reload. The
+// callers are committed once they are known to be valid, just before
+// they are swapped in.
+func (d *Daemon) swap(callers []Caller, commit func() error) (ReloadEvent, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	var event ReloadEvent
+	registry := make(map[string]Caller, len(d.registry))
+	for key, c := range d.registry {
+		if !d.sourced[key] {
+			registry[key] = c
+		}
+	}
+
+	sourced := make(map[string]bool, len(callers))
+	for _, c := range callers {
+		if _, ok := registry[c.key]; ok {
+			return event, fmt.Errorf(
+				"caller has already been registered with the dependency and name of %q and %q",
+				c.dependency,
+				c.name,
+			)
+		}
+		registry[c.key] = c
+		sourced[c.key] = true
+
+		if d.sourced[c.key] {
+			event.Updated = append(event.Updated, c.key)
+		} else {
+			event.Added = append(event.Added, c.key)
+		}
+	}
+	for key := range d.sourced {
+		if !sourced[key] {
+			event.Removed = append(event.Removed, key)
+		}
+	}
+
+	sort.Strings(event.Added)
+	sort.Strings(event.Updated)
+	sort.Strings(event.Removed)
+
+	if err := commit(); err != nil {
+		return ReloadEvent{}, err
+	}
+
+	d.registry = registry
+	d.sourced = sourced
+	return event, nil
+}
+
+func (d *Daemon) watchConfig() {
+	ticker := time.NewTicker(d.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.Reload(d.ctx)
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package errcat_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agschwender/errcat-go"
+	"github.com/agschwender/errcat-go/retrier"
+)
+
+type fakeSource struct {
+	lock       sync.Mutex
+	callers    []errcat.Caller
+	changed    bool
+	commits    int
+	err        error
+	loading    bool
+	overlapped bool
+}
+
+func (s *fakeSource) set(callers []errcat.Caller, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.callers, s.changed, s.err = callers, true, err
+}
+
+func (s *fakeSource) Callers(context.Context) ([]errcat.Caller, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	changed := s.changed
+	s.changed = false
+	if changed && s.err == nil {
+		// A load is outstanding until it is committed, so loading again
+		// beforehand means reloads overlapped.
+		s.overlapped = s.overlapped || s.loading
+		s.loading = true
+	}
+	return s.callers, changed, s.err
+}
+
+func (s *fakeSource) Commit() error {
+	// Widen the window between loading and committing, so that
+	// overlapping reloads are detected.
+	time.Sleep(time.Millisecond)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.commits++
+	s.loading = false
+	return nil
+}
+
+func withAttempts(c errcat.Caller, n uint) errcat.Caller {
+	return c.WithRetrier(retrier.New(retrier.WithMaxAttempts(n)))
+}
+
+func TestDaemonReload(t *testing.T) {
+	src := &fakeSource{}
+	src.set([]errcat.Caller{
+		withAttempts(errcat.New("mysql", "users.GetUser"), 2),
+		errcat.New("mysql", "users.ListUsers"),
+	}, nil)
+
+	var events []errcat.ReloadEvent
+	d := errcat.NewD(
+		errcat.WithConfigSource(src, time.Hour),
+		errcat.WithReloadHook(func(e errcat.ReloadEvent) { events = append(events, e) }),
+	)
+
+	codeKey, err := d.RegisterCaller(errcat.New("google", "clients.Google.Search"))
+	require.NoError(t, err)
+
+	d.Start()
+	defer d.Stop()
+
+	attempts := func(key string) int {
+		n := 0
+		d.Call(key, func() error {
+			n++
+			return fmt.Errorf("oops")
+		})
+		return n
+	}
+
+	require.Len(t, events, 1)
+	assert.Equal(t, errcat.ReloadEvent{Added: []string{"mysql:users.GetUser", "mysql:users.ListUsers"}}, events[0])
+	assert.Equal(t, 2, attempts("mysql:users.GetUser"))
+
+	// Confirm nothing happens when the source is unchanged
+	require.NoError(t, d.Reload(context.Background()))
+	assert.Len(t, events, 1)
+
+	// Confirm the callers are swapped in
+	src.set([]errcat.Caller{
+		withAttempts(errcat.New("mysql", "users.GetUser"), 3),
+		errcat.New("mysql", "users.DeleteUser"),
+	}, nil)
+	require.NoError(t, d.Reload(context.Background()))
+	require.Len(t, events, 2)
+	assert.Equal(t, errcat.ReloadEvent{
+		Added:   []string{"mysql:users.DeleteUser"},
+		Updated: []string{"mysql:users.GetUser"},
+		Removed: []string{"mysql:users.ListUsers"},
+	}, events[1])
+	assert.Equal(t, 3, attempts("mysql:users.GetUser"))
+
+	// Confirm failures keep the previous callers
+	src.set(nil, fmt.Errorf("oops"))
+	assert.EqualError(t, d.Reload(context.Background()), "reload failed: oops")
+	require.Len(t, events, 3)
+	assert.EqualError(t, events[2].Err, "reload failed: oops")
+	assert.Equal(t, 3, attempts("mysql:users.GetUser"))
+	assert.Equal(t, 2, src.commits)
+
+	// Confirm the source may not replace callers registered in code, and
+	// that the rejected callers are not committed
+	src.set([]errcat.Caller{errcat.New("google", "clients.Google.Search")}, nil)
+	assert.Error(t, d.Reload(context.Background()))
+	assert.Equal(t, 2, src.commits)
+	assert.Equal(t, 3, attempts("mysql:users.GetUser"))
+	assert.Equal(t, 1, attempts(codeKey))
+
+	// Confirm callers registered in code are kept
+	src.set(nil, nil)
+	require.NoError(t, d.Reload(context.Background()))
+	assert.Equal(t, errcat.ReloadEvent{
+		Removed: []string{"mysql:users.DeleteUser", "mysql:users.GetUser"},
+	}, events[len(events)-1])
+	assert.Equal(t, 1, attempts(codeKey))
+
+	_, err = d.RegisterCaller(errcat.New("mysql", "users.GetUser"))
+	assert.NoError(t, err)
+}
+
+func TestDaemonReloadConcurrent(t *testing.T) {
+	src := &fakeSource{}
+	d := errcat.NewD(errcat.WithConfigSource(src, time.Hour))
+
+	// Confirm concurrent reloads each commit the load they swapped in
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			src.set([]errcat.Caller{errcat.New("mysql", fmt.Sprintf("users.Get%d", i))}, nil)
+			assert.NoError(t, d.Reload(context.Background()))
+		}(i)
+	}
+	wg.Wait()
+
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	assert.False(t, src.overlapped)
+}
+
+func TestDaemonReloadPolling(t *testing.T) {
+	src := &fakeSource{}
+	src.set(nil, nil)
+
+	events := make(chan errcat.ReloadEvent, 1)
+	d := errcat.NewD(
+		errcat.WithConfigSource(src, time.Millisecond),
+		errcat.WithReloadHook(func(e errcat.ReloadEvent) { events <- e }),
+	)
+	d.Start()
+	defer d.Stop()
+	<-events
+
+	src.set([]errcat.Caller{errcat.New("mysql", "users.GetUser")}, nil)
+	select {
+	case e := <-events:
+		assert.Equal(t, []string{"mysql:users.GetUser"}, e.Added)
+	case <-time.After(time.Second):
+		t.Fatal("config source was not polled")
+	}
+}
@@ -0,0 +1,104 @@
+package retrier
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff determines the delay before the next attempt. It receives the
+// number of attempts made so far, which is always at least one, and the
+// delay that preceded the last attempt, which is zero after the first
+// attempt.
+type Backoff func(attempt uint, prev time.Duration) time.Duration
+
+// Constant waits the same duration between each attempt.
+func Constant(d time.Duration) Backoff {
+	return func(uint, time.Duration) time.Duration {
+		return d
+	}
+}
+
+// Linear increases the delay by step after each attempt, starting at
+// the initial duration and never exceeding max. A max of zero indicates
+// there is no limit.
+func Linear(initial, step, max time.Duration) Backoff {
+	return func(attempt uint, _ time.Duration) time.Duration {
+		return capped(float64(initial)+float64(step)*float64(attempt-1), max)
+	}
+}
+
+// Exponential doubles the delay after each attempt, starting at the base
+// duration and never exceeding max. A max of zero indicates there is no
+// limit.
+func Exponential(base, max time.Duration) Backoff {
+	return func(attempt uint, _ time.Duration) time.Duration {
+		return exponential(base, max, attempt)
+	}
+}
+
+// FullJitter waits a random duration between zero and the exponential
+// delay. This spreads out attempts the most, at the cost of sometimes
+// retrying almost immediately.
+func FullJitter(base, max time.Duration) Backoff {
+	return func(attempt uint, _ time.Duration) time.Duration {
+		return random(0, exponential(base, max, attempt))
+	}
+}
+
+// EqualJitter waits at least half of the exponential delay, plus a
+// random duration up to the other half.
+func EqualJitter(base, max time.Duration) Backoff {
+	return func(attempt uint, _ time.Duration) time.Duration {
+		d := exponential(base, max, attempt)
+		return d/2 + random(0, d-d/2)
+	}
+}
+
+// DecorrelatedJitter waits a random duration between the base and three
+// times the previous delay, never exceeding max. Unlike the other
+// jitter variants, the delay grows based on the previous delay rather
+// than the attempt number.
+func DecorrelatedJitter(base, max time.Duration) Backoff {
+	return func(_ uint, prev time.Duration) time.Duration {
+		if prev < base {
+			prev = base
+		}
+		return capped(float64(random(base, 3*prev)), max)
+	}
+}
+
+func capped(d float64, max time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	if max > 0 && d >= float64(max) {
+		return max
+	}
+	if d >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+func exponential(base, max time.Duration, attempt uint) time.Duration {
+	return capped(float64(base)*math.Pow(2, float64(attempt-1)), max)
+}
+
+var (
+	rngLock sync.Mutex
+	rng     = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// random returns a random duration in the range [min, max).
+func random(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+
+	rngLock.Lock()
+	defer rngLock.Unlock()
+
+	return min + time.Duration(rng.Int63n(int64(max-min)))
+}
@@ -0,0 +1,77 @@
+package retrier_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/agschwender/errcat-go/retrier"
+)
+
+func TestConstant(t *testing.T) {
+	b := retrier.Constant(time.Duration(100) * time.Millisecond)
+	for i := uint(1); i < 5; i++ {
+		assert.Equal(t, time.Duration(100)*time.Millisecond, b(i, 0))
+	}
+}
+
+func TestLinear(t *testing.T) {
+	b := retrier.Linear(time.Duration(100)*time.Millisecond, time.Duration(50)*time.Millisecond, time.Duration(200)*time.Millisecond)
+	assert.Equal(t, time.Duration(100)*time.Millisecond, b(1, 0))
+	assert.Equal(t, time.Duration(150)*time.Millisecond, b(2, 0))
+	assert.Equal(t, time.Duration(200)*time.Millisecond, b(3, 0))
+	assert.Equal(t, time.Duration(200)*time.Millisecond, b(4, 0))
+
+	// Confirm there is no limit without a max
+	b = retrier.Linear(0, time.Second, 0)
+	assert.Equal(t, time.Duration(99)*time.Second, b(100, 0))
+}
+
+func TestExponential(t *testing.T) {
+	b := retrier.Exponential(time.Duration(100)*time.Millisecond, time.Second)
+	assert.Equal(t, time.Duration(100)*time.Millisecond, b(1, 0))
+	assert.Equal(t, time.Duration(200)*time.Millisecond, b(2, 0))
+	assert.Equal(t, time.Duration(400)*time.Millisecond, b(3, 0))
+	assert.Equal(t, time.Duration(800)*time.Millisecond, b(4, 0))
+	assert.Equal(t, time.Second, b(5, 0))
+	assert.Equal(t, time.Second, b(1000, 0))
+
+	// Confirm large attempts do not overflow without a max
+	b = retrier.Exponential(time.Second, 0)
+	assert.True(t, b(1000, 0) > 0)
+}
+
+func TestFullJitter(t *testing.T) {
+	b := retrier.FullJitter(time.Duration(100)*time.Millisecond, time.Second)
+	for i := 0; i < 100; i++ {
+		d := b(3, 0)
+		assert.True(t, d >= 0 && d < time.Duration(400)*time.Millisecond, "%v", d)
+
+		d = b(10, 0)
+		assert.True(t, d >= 0 && d < time.Second, "%v", d)
+	}
+}
+
+func TestEqualJitter(t *testing.T) {
+	b := retrier.EqualJitter(time.Duration(100)*time.Millisecond, time.Second)
+	for i := 0; i < 100; i++ {
+		d := b(3, 0)
+		assert.True(t, d >= time.Duration(200)*time.Millisecond && d < time.Duration(400)*time.Millisecond, "%v", d)
+	}
+}
+
+func TestDecorrelatedJitter(t *testing.T) {
+	base := time.Duration(100) * time.Millisecond
+	b := retrier.DecorrelatedJitter(base, time.Second)
+	for i := 0; i < 100; i++ {
+		d := b(1, 0)
+		assert.True(t, d >= base && d < 3*base, "%v", d)
+
+		d = b(2, time.Duration(200)*time.Millisecond)
+		assert.True(t, d >= base && d < time.Duration(600)*time.Millisecond, "%v", d)
+
+		d = b(3, time.Second)
+		assert.True(t, d >= base && d <= time.Second, "%v", d)
+	}
+}
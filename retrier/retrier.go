@@ -1,12 +1,76 @@
 package retrier
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agschwender/errcat-go/internal/errs"
+)
+
 const defaultMaxAttempts = uint(1)
 
+var defaultBackoff = Constant(time.Duration(0))
+
 var defaultIsRetriable = func(err error) bool { return err != nil }
 
+// RetriesExhaustedError is the error returned when the call was retried
+// and every attempt failed. It unwraps to the error of the last
+// attempt, while errors.Is and errors.As consider the errors of all of
+// the attempts.
+type RetriesExhaustedError struct {
+	// Dependency and Name identify the caller that made the call, when
+	// the retrier is run by one.
+	Dependency string
+	Name       string
+
+	// Errors are the errors of each attempt, in the order they were
+	// made.
+	Errors []error
+}
+
+func (e *RetriesExhaustedError) Error() string {
+	return errs.Describe(e.Dependency, e.Name, fmt.Sprintf(
+		"retries exhausted after %d attempts: %v", len(e.Errors), e.Unwrap(),
+	))
+}
+
+func (e *RetriesExhaustedError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors[len(e.Errors)-1]
+}
+
+// Is reports whether the error of any attempt matches the target.
+func (e *RetriesExhaustedError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of the attempts that matches the target.
+func (e *RetriesExhaustedError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 type Retrier struct {
-	isRetriable func(err error) bool
-	maxAttempts uint
+	backoff        Backoff
+	budgetFraction float64
+	isRetriable    func(err error) bool
+	maxAttempts    uint
+	maxElapsedTime time.Duration
+	now            func() time.Time
+	sleep          func(ctx context.Context, d time.Duration) error
 }
 
 type option func(*Retrier)
@@ -14,8 +78,11 @@ type option func(*Retrier)
 // New creates a new Retrier with the supplied options.
 func New(opts ...option) *Retrier {
 	r := &Retrier{
+		backoff:     defaultBackoff,
 		isRetriable: defaultIsRetriable,
 		maxAttempts: defaultMaxAttempts,
+		now:         time.Now,
+		sleep:       sleep,
 	}
 
 	for _, opt := range opts {
@@ -25,6 +92,32 @@ func New(opts ...option) *Retrier {
 	return r
 }
 
+// WithBackoff defines the delay between attempts. By default, attempts
+// are made immediately after one another.
+func WithBackoff(backoff Backoff) option {
+	return func(r *Retrier) {
+		if backoff == nil {
+			backoff = defaultBackoff
+		}
+		r.backoff = backoff
+	}
+}
+
+// WithBudgetFraction makes the retrier aware of the deadline of the
+// context, which is the remaining budget of the call. Another attempt
+// will not be made if the time remaining after waiting for it is below
+// the fraction of the average duration of the attempts made so far,
+// since it is unlikely to complete in time. A value of zero indicates
+// the deadline is not considered.
+func WithBudgetFraction(fraction float64) option {
+	return func(r *Retrier) {
+		if fraction < 0 {
+			fraction = 0
+		}
+		r.budgetFraction = fraction
+	}
+}
+
 // WithIsRetriable defines the logic for determining if the error should
 // be retried.
 func WithIsRetriable(isRetriable func(err error) bool) option {
@@ -47,19 +140,121 @@ func WithMaxAttempts(maxAttempts uint) option {
 	}
 }
 
+// WithMaxElapsedTime limits the total time spent retrying. Another
+// attempt will not be made if waiting for it would exceed the maximum
+// elapsed time since the first attempt began. A value of zero indicates
+// there is no limit.
+func WithMaxElapsedTime(maxElapsedTime time.Duration) option {
+	return func(r *Retrier) {
+		r.maxElapsedTime = maxElapsedTime
+	}
+}
+
+// WithNow sets the function for getting the current time. This is only
+// useful for testing.
+func WithNow(now func() time.Time) option {
+	return func(r *Retrier) {
+		if now == nil {
+			now = time.Now
+		}
+		r.now = now
+	}
+}
+
+// WithSleep sets the function used to wait between attempts. The
+// function must return the context's error if it is done before the
+// duration has passed. This is only useful for testing.
+func WithSleep(fn func(ctx context.Context, d time.Duration) error) option {
+	return func(r *Retrier) {
+		if fn == nil {
+			fn = sleep
+		}
+		r.sleep = fn
+	}
+}
+
 // Run executes the callback until it succeeds or the maximum number of
 // attempts is reached.
 func (r *Retrier) Run(cb func() error) error {
-	if r == nil {
+	return r.RunContext(context.Background(), func(context.Context) error {
 		return cb()
+	})
+}
+
+// RunContext executes the callback until it succeeds, the maximum
+// number of attempts is reached or the context is done. The context is
+// checked between attempts, including while waiting on the backoff, so
+// an attempt that is already running is left to honor the context
+// itself. If the callback was retried and the retrier gave up, a
+// RetriesExhaustedError is returned.
+func (r *Retrier) RunContext(ctx context.Context, cb func(ctx context.Context) error) error {
+	if r == nil {
+		return cb(ctx)
 	}
 
-	var err error
+	startedAt := r.now()
+
+	var failures []error
+	var delay, spent time.Duration
 	for i := uint(0); i < r.maxAttempts; i++ {
-		err = cb()
+		if i > 0 {
+			delay = r.backoff(i, delay)
+			if r.maxElapsedTime > 0 && r.now().Add(delay).Sub(startedAt) > r.maxElapsedTime {
+				break
+			}
+			if !r.withinBudget(ctx, delay, spent/time.Duration(i)) {
+				break
+			}
+			if delay > 0 {
+				if sleepErr := r.sleep(ctx, delay); sleepErr != nil {
+					return sleepErr
+				}
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+
+		attemptedAt := r.now()
+		err := cb(ctx)
 		if err == nil || !r.isRetriable(err) {
 			return err
 		}
+		spent += r.now().Sub(attemptedAt)
+		failures = append(failures, err)
+	}
+
+	if len(failures) == 1 {
+		return failures[0]
 	}
-	return err
+	return &RetriesExhaustedError{Errors: failures}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// withinBudget indicates whether another attempt of the observed
+// latency, made after the delay, is likely to complete before the
+// deadline of the context.
+func (r *Retrier) withinBudget(ctx context.Context, delay, latency time.Duration) bool {
+	if r.budgetFraction == 0 {
+		return true
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+
+	remaining := deadline.Sub(r.now()) - delay
+	return float64(remaining) >= r.budgetFraction*float64(latency)
 }
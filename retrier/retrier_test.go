@@ -1,8 +1,11 @@
 package retrier_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -73,10 +76,148 @@ func TestWithOverrides(t *testing.T) {
 	assert.Equal(t, 2, counts)
 }
 
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+func TestWithBackoff(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	r := retrier.New(
+		retrier.WithBackoff(retrier.Exponential(time.Duration(100)*time.Millisecond, 0)),
+		retrier.WithMaxAttempts(4),
+		retrier.WithNow(clock.Now),
+		retrier.WithSleep(clock.Sleep),
+	)
+
+	counts := 0
+	err := r.Run(func() error {
+		counts++
+		return fmt.Errorf("oops")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 4, counts)
+	assert.Equal(t, []time.Duration{
+		time.Duration(100) * time.Millisecond,
+		time.Duration(200) * time.Millisecond,
+		time.Duration(400) * time.Millisecond,
+	}, clock.sleeps)
+
+	// Confirm the previous delay is supplied to the backoff
+	var prevs []time.Duration
+	clock.sleeps = nil
+	r = retrier.New(
+		retrier.WithBackoff(func(attempt uint, prev time.Duration) time.Duration {
+			prevs = append(prevs, prev)
+			return prev + time.Second
+		}),
+		retrier.WithMaxAttempts(3),
+		retrier.WithNow(clock.Now),
+		retrier.WithSleep(clock.Sleep),
+	)
+	r.Run(func() error { return fmt.Errorf("oops") })
+	assert.Equal(t, []time.Duration{0, time.Second}, prevs)
+	assert.Equal(t, []time.Duration{time.Second, time.Duration(2) * time.Second}, clock.sleeps)
+}
+
+func TestWithMaxElapsedTime(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	r := retrier.New(
+		retrier.WithBackoff(retrier.Constant(time.Second)),
+		retrier.WithMaxAttempts(10),
+		retrier.WithMaxElapsedTime(time.Duration(3500)*time.Millisecond),
+		retrier.WithNow(clock.Now),
+		retrier.WithSleep(clock.Sleep),
+	)
+
+	counts := 0
+	err := r.Run(func() error {
+		counts++
+		return fmt.Errorf("oops")
+	})
+	require.Error(t, err)
+	assert.Equal(t, "retries exhausted after 4 attempts: oops", err.Error())
+	assert.Equal(t, 4, counts)
+	assert.Len(t, clock.sleeps, 3)
+}
+
+func TestWithBudgetFraction(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	r := retrier.New(
+		retrier.WithBackoff(retrier.Constant(time.Second)),
+		retrier.WithBudgetFraction(0.5),
+		retrier.WithMaxAttempts(10),
+		retrier.WithNow(clock.Now),
+		retrier.WithSleep(clock.Sleep),
+	)
+
+	// Each attempt takes 4s, so another attempt is only made while at
+	// least 2s remain after the delay.
+	ctx, cancel := context.WithDeadline(context.Background(), clock.now.Add(time.Duration(11500)*time.Millisecond))
+	defer cancel()
+
+	counts := 0
+	err := r.RunContext(ctx, func(context.Context) error {
+		counts++
+		clock.now = clock.now.Add(time.Duration(4) * time.Second)
+		return fmt.Errorf("oops")
+	})
+	assert.EqualError(t, err, "retries exhausted after 2 attempts: oops")
+	assert.Equal(t, 2, counts)
+
+	// Confirm the deadline is ignored by default
+	r = retrier.New(
+		retrier.WithMaxAttempts(3),
+		retrier.WithNow(clock.Now),
+		retrier.WithSleep(clock.Sleep),
+	)
+	ctx, cancel = context.WithDeadline(context.Background(), clock.now.Add(time.Second))
+	defer cancel()
+
+	counts = 0
+	err = r.RunContext(ctx, func(context.Context) error {
+		counts++
+		clock.now = clock.now.Add(time.Duration(4) * time.Second)
+		return fmt.Errorf("oops")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, counts)
+}
+
+func TestWithBackoffCancelled(t *testing.T) {
+	r := retrier.New(
+		retrier.WithBackoff(retrier.Constant(time.Hour)),
+		retrier.WithMaxAttempts(3),
+	)
+
+	// Confirm the default sleep is interrupted by the context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(10)*time.Millisecond)
+	defer cancel()
+
+	counts := 0
+	err := r.RunContext(ctx, func(ctx context.Context) error {
+		counts++
+		return fmt.Errorf("oops")
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, counts)
+}
+
 func TestWithZeroValues(t *testing.T) {
 	r := retrier.New(
+		retrier.WithBackoff(nil),
 		retrier.WithIsRetriable(nil),
 		retrier.WithMaxAttempts(0),
+		retrier.WithNow(nil),
+		retrier.WithSleep(nil),
 	)
 
 	// Confirm attempts with error
@@ -88,3 +229,71 @@ func TestWithZeroValues(t *testing.T) {
 	require.Error(t, err)
 	assert.Equal(t, 1, counts)
 }
+
+func TestRunContext(t *testing.T) {
+	r := retrier.New(retrier.WithMaxAttempts(3))
+
+	// Confirm the context is passed to each attempt
+	counts := 0
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	err := r.RunContext(ctx, func(ctx context.Context) error {
+		counts++
+		assert.Equal(t, "value", ctx.Value(key{}))
+		return fmt.Errorf("oops")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 3, counts)
+
+	// Confirm no further attempts are made once the context is done
+	counts = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	err = r.RunContext(ctx, func(ctx context.Context) error {
+		counts++
+		cancel()
+		return fmt.Errorf("oops")
+	})
+	require.Error(t, err)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, counts)
+}
+
+func TestRetriesExhaustedError(t *testing.T) {
+	r := retrier.New(retrier.WithMaxAttempts(3))
+
+	errs := []error{context.DeadlineExceeded, fmt.Errorf("oops"), fmt.Errorf("last")}
+	counts := 0
+	err := r.Run(func() error {
+		counts++
+		return errs[counts-1]
+	})
+	require.Error(t, err)
+	assert.Equal(t, "retries exhausted after 3 attempts: last", err.Error())
+	assert.Equal(t, errs[2], errors.Unwrap(err))
+
+	// Confirm the errors of every attempt are considered
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var exhaustedErr *retrier.RetriesExhaustedError
+	require.ErrorAs(t, err, &exhaustedErr)
+	assert.Equal(t, errs, exhaustedErr.Errors)
+
+	exhaustedErr.Dependency, exhaustedErr.Name = "mysql", "users.GetUser"
+	assert.Equal(t, "mysql:users.GetUser: retries exhausted after 3 attempts: last", err.Error())
+
+	// Confirm a non-retriable error is returned as is
+	r = retrier.New(
+		retrier.WithIsRetriable(func(err error) bool { return err.Error() != "perm err" }),
+		retrier.WithMaxAttempts(3),
+	)
+	counts = 0
+	err = r.Run(func() error {
+		counts++
+		if counts == 2 {
+			return fmt.Errorf("perm err")
+		}
+		return fmt.Errorf("oops")
+	})
+	require.Error(t, err)
+	assert.Equal(t, "perm err", err.Error())
+}
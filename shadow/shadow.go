@@ -0,0 +1,27 @@
+// Package shadow allows policies running in shadow mode to report the
+// calls they would have rejected. In shadow mode, a policy tracks its
+// state as usual but never rejects a call, which allows its settings to
+// be tuned against real traffic before they are enforced.
+//
+// The daemon records the rejections reported while making a call. Other
+// code may collect them by supplying its own function with NewContext.
+package shadow
+
+import "context"
+
+type reportKey struct{}
+
+// NewContext returns a copy of the context that reports the rejections
+// of the call made with it to the function. A nil function discards
+// them.
+func NewContext(ctx context.Context, report func(err error)) context.Context {
+	return context.WithValue(ctx, reportKey{}, report)
+}
+
+// Report reports that a policy in shadow mode would have rejected the
+// call made with the context with the error.
+func Report(ctx context.Context, err error) {
+	if report, _ := ctx.Value(reportKey{}).(func(err error)); report != nil {
+		report(err)
+	}
+}
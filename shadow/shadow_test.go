@@ -0,0 +1,26 @@
+package shadow_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/agschwender/errcat-go/shadow"
+)
+
+func TestReport(t *testing.T) {
+	// Confirm reports without a function are discarded
+	shadow.Report(context.Background(), fmt.Errorf("oops"))
+	shadow.Report(shadow.NewContext(context.Background(), nil), fmt.Errorf("oops"))
+
+	var reported []error
+	ctx := shadow.NewContext(context.Background(), func(err error) {
+		reported = append(reported, err)
+	})
+
+	errOops := fmt.Errorf("oops")
+	shadow.Report(ctx, errOops)
+	assert.Equal(t, []error{errOops}, reported)
+}
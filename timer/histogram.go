@@ -0,0 +1,74 @@
+package timer
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// The buckets grow by 10% from 10µs, which covers latencies of up to
+	// an hour with an error of at most 10%.
+	histogramMin    = float64(10 * time.Microsecond)
+	histogramGrowth = 1.1
+	histogramSize   = 210
+
+	// Once the histogram holds this many samples, the counts are halved
+	// so that older latencies carry less weight than recent ones.
+	histogramDecayAt = 1000
+)
+
+// histogram is a streaming latency histogram with exponentially sized
+// buckets. It favours recent latencies by periodically decaying the
+// counts of its buckets.
+type histogram struct {
+	buckets [histogramSize]float64
+	total   float64
+}
+
+func (h *histogram) record(d time.Duration) {
+	h.buckets[bucketOf(d)]++
+	h.total++
+
+	if h.total >= histogramDecayAt {
+		h.total = 0
+		for i := range h.buckets {
+			h.buckets[i] /= 2
+			h.total += h.buckets[i]
+		}
+	}
+}
+
+// quantile returns the latency at or below which the fraction q of the
+// recorded latencies fall. It is the upper bound of the bucket holding
+// that latency.
+func (h *histogram) quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	target := q * h.total
+	var seen float64
+	for i, count := range h.buckets {
+		seen += count
+		if seen >= target && count > 0 {
+			return bucketBound(i)
+		}
+	}
+	return bucketBound(histogramSize - 1)
+}
+
+func bucketOf(d time.Duration) int {
+	if float64(d) <= histogramMin {
+		return 0
+	}
+
+	i := int(math.Ceil(math.Log(float64(d)/histogramMin) / math.Log(histogramGrowth)))
+	if i >= histogramSize {
+		return histogramSize - 1
+	}
+	return i
+}
+
+func bucketBound(i int) time.Duration {
+	return time.Duration(histogramMin * math.Pow(histogramGrowth, float64(i)))
+}
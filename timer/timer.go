@@ -1,24 +1,189 @@
 package timer
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
+
+	"github.com/agschwender/errcat-go/internal/errs"
 )
 
 // ErrTimeout indicates that the function run by the timer failed to
 // complete before the timer ran out.
 var ErrTimeout = errors.New("timeout exceeded")
 
+// TimeoutError is the error returned when the timer runs out. It
+// satisfies errors.Is for ErrTimeout.
+type TimeoutError struct {
+	// Dependency and Name identify the caller that made the call, when
+	// the timer is run by one.
+	Dependency string
+	Name       string
+
+	// Deadline is the time at which the timer ran out.
+	Deadline time.Time
+
+	// Timeout is the duration of the timer when the call was made. For
+	// an adaptive timer, this varies from call to call.
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return errs.Describe(e.Dependency, e.Name, fmt.Sprintf("%s after %s", ErrTimeout, e.Timeout))
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return ErrTimeout
+}
+
+const (
+	defaultMultiplier = 1.5
+	defaultPercentile = 0.99
+	defaultWarmup     = 100
+)
+
 type Timer struct {
 	duration time.Duration
+	adaptive *adaptive
+}
+
+// adaptive holds the settings and observed latencies of an adaptive
+// timer.
+type adaptive struct {
+	ceiling    time.Duration
+	floor      time.Duration
+	multiplier float64
+	percentile float64
+	warmup     uint
+
+	lock      sync.RWMutex
+	latencies histogram
+	samples   uint
+	timeout   time.Duration
 }
 
+type option func(*adaptive)
+
 // New creates a new Timer with the supplied duration.
 func New(d time.Duration) *Timer {
 	return &Timer{duration: d}
 }
 
+// NewAdaptive creates a new Timer whose timeout is derived from the
+// latencies it observes, rather than fixed. The timeout is the
+// percentile of the latencies multiplied by the multiplier, by default
+// p99 × 1.5, clamped between the floor and ceiling. Until enough
+// latencies have been observed to warm up, the supplied duration is
+// used.
+func NewAdaptive(d time.Duration, opts ...option) *Timer {
+	a := &adaptive{
+		multiplier: defaultMultiplier,
+		percentile: defaultPercentile,
+		warmup:     defaultWarmup,
+		timeout:    d,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return &Timer{duration: d, adaptive: a}
+}
+
+// WithCeiling sets the maximum timeout of an adaptive timer. A value of
+// zero indicates there is no maximum.
+func WithCeiling(ceiling time.Duration) option {
+	return func(a *adaptive) {
+		a.ceiling = ceiling
+	}
+}
+
+// WithFloor sets the minimum timeout of an adaptive timer, which
+// prevents a run of fast calls from making the timeout too tight. A
+// value of zero indicates there is no minimum.
+func WithFloor(floor time.Duration) option {
+	return func(a *adaptive) {
+		a.floor = floor
+	}
+}
+
+// WithMultiplier sets the factor the percentile latency is multiplied
+// by to determine the timeout of an adaptive timer, which gives calls
+// headroom beyond the latency observed.
+func WithMultiplier(multiplier float64) option {
+	return func(a *adaptive) {
+		if multiplier <= 0 {
+			multiplier = defaultMultiplier
+		}
+		a.multiplier = multiplier
+	}
+}
+
+// WithPercentile sets the percentile of the observed latencies, between
+// 0 and 1, that an adaptive timer bases its timeout on.
+func WithPercentile(percentile float64) option {
+	return func(a *adaptive) {
+		if percentile <= 0 || percentile > 1 {
+			percentile = defaultPercentile
+		}
+		a.percentile = percentile
+	}
+}
+
+// WithWarmup sets the number of latencies an adaptive timer observes
+// before it adapts its timeout. Until then, the timer's duration is
+// used.
+func WithWarmup(warmup uint) option {
+	return func(a *adaptive) {
+		if warmup == 0 {
+			warmup = defaultWarmup
+		}
+		a.warmup = warmup
+	}
+}
+
+// Timeout returns the current timeout of the timer. This is the
+// duration of a static timer, whereas an adaptive timer's changes as it
+// observes latencies.
+func (t *Timer) Timeout() time.Duration {
+	if t == nil {
+		return 0
+	}
+	if t.adaptive == nil {
+		return t.duration
+	}
+
+	t.adaptive.lock.RLock()
+	defer t.adaptive.lock.RUnlock()
+
+	return t.adaptive.timeout
+}
+
+// observe records the latency of a call and adapts the timeout.
+// Calls that time out are recorded with the timeout, since their actual
+// latency is unknown.
+func (a *adaptive) observe(d time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.latencies.record(d)
+	a.samples++
+	if a.samples < a.warmup {
+		return
+	}
+
+	timeout := time.Duration(float64(a.latencies.quantile(a.percentile)) * a.multiplier)
+	if a.floor > 0 && timeout < a.floor {
+		timeout = a.floor
+	}
+	if a.ceiling > 0 && timeout > a.ceiling {
+		timeout = a.ceiling
+	}
+	a.timeout = timeout
+}
+
 // Run executes the callback ensuring it returns by the timeout
 // duration. Note, this does NOT kill the function call, it will still
 // complete in the background. In general, it is preferable timeout
@@ -26,28 +191,55 @@ func New(d time.Duration) *Timer {
 // those cases where that functionality is not provided, this timer
 // functionality may be appropriate.
 func (t *Timer) Run(cb func() error) error {
-	if t == nil || t.duration <= time.Duration(0) {
+	return t.RunContext(context.Background(), func(context.Context) error {
 		return cb()
+	})
+}
+
+// RunContext executes the callback ensuring it returns by the timeout
+// duration. The callback receives a child context that is cancelled
+// once the timeout is exceeded, allowing it to stop any outstanding
+// work. If the supplied context is done before the timeout, its error
+// is returned instead of a TimeoutError.
+func (t *Timer) RunContext(ctx context.Context, cb func(ctx context.Context) error) error {
+	timeout := t.Timeout()
+	if timeout <= time.Duration(0) {
+		return cb(ctx)
 	}
 
-	timer := time.NewTimer(t.duration)
-	defer timer.Stop()
+	startedAt := time.Now()
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	done := make(chan error)
+	// The channel is buffered so that the goroutine can exit even when
+	// its result is no longer being waited on.
+	done := make(chan error, 1)
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				done <- fmt.Errorf("%v", r)
+				done <- errs.NewPanicError(r)
 			}
 		}()
 
-		done <- cb()
+		done <- cb(tctx)
 	}()
 
 	select {
-	case <-timer.C:
-		return ErrTimeout
+	case <-tctx.Done():
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		t.observe(timeout)
+		deadline, _ := tctx.Deadline()
+		return &TimeoutError{Deadline: deadline, Timeout: timeout}
 	case err := <-done:
+		t.observe(time.Since(startedAt))
 		return err
 	}
 }
+
+func (t *Timer) observe(d time.Duration) {
+	if t.adaptive != nil {
+		t.adaptive.observe(d)
+	}
+}
@@ -1,6 +1,7 @@
 package timer_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -42,5 +43,114 @@ func TestTimer(t *testing.T) {
 		time.Sleep(time.Duration(75) * time.Millisecond)
 		return nil
 	})
-	assert.Equal(t, timer.ErrTimeout, err)
+	assert.ErrorIs(t, err, timer.ErrTimeout)
+}
+
+func TestTimerRunContext(t *testing.T) {
+	tmr := timer.New(time.Duration(50) * time.Millisecond)
+
+	// The callback context is cancelled once the timeout is exceeded
+	cancelled := make(chan error, 1)
+	startedAt := time.Now()
+	err := tmr.RunContext(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, timer.ErrTimeout)
+	assert.Equal(t, "timeout exceeded after 50ms", err.Error())
+
+	var timeoutErr *timer.TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, time.Duration(50)*time.Millisecond, timeoutErr.Timeout)
+	assert.WithinDuration(t, startedAt.Add(timeoutErr.Timeout), timeoutErr.Deadline, time.Duration(10)*time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, <-cancelled)
+
+	// Cancelling the parent context returns its error rather than a
+	// timeout.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = tmr.RunContext(ctx, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.Equal(t, context.Canceled, err)
+
+	// Without a timer, the context is passed through as is
+	var nilTmr *timer.Timer
+	err = nilTmr.RunContext(ctx, func(ctx context.Context) error {
+		return ctx.Err()
+	})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestAdaptive(t *testing.T) {
+	tm := timer.NewAdaptive(time.Second, timer.WithWarmup(10))
+	assert.Equal(t, time.Second, tm.Timeout())
+
+	run := func(d time.Duration) error {
+		return tm.Run(func() error {
+			time.Sleep(d)
+			return nil
+		})
+	}
+
+	// Confirm the static duration is used while warming up
+	for i := 0; i < 9; i++ {
+		require.NoError(t, run(time.Millisecond))
+	}
+	assert.Equal(t, time.Second, tm.Timeout())
+
+	// Confirm the timeout adapts to the observed latencies, allowing
+	// for the accuracy of the histogram and of sleeping
+	require.NoError(t, run(time.Millisecond))
+	assert.Greater(t, tm.Timeout(), time.Duration(1500)*time.Microsecond)
+	assert.Less(t, tm.Timeout(), time.Duration(100)*time.Millisecond)
+}
+
+func TestAdaptiveTimeout(t *testing.T) {
+	tm := timer.NewAdaptive(
+		time.Duration(5)*time.Millisecond,
+		timer.WithMultiplier(2),
+		timer.WithPercentile(0.5),
+		timer.WithWarmup(1),
+	)
+
+	// Confirm calls that time out are observed at the timeout, which
+	// allows the timeout to grow
+	err := tm.RunContext(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var timeoutErr *timer.TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, time.Duration(5)*time.Millisecond, timeoutErr.Timeout)
+	assert.GreaterOrEqual(t, tm.Timeout(), time.Duration(10)*time.Millisecond)
+}
+
+func TestAdaptiveBounds(t *testing.T) {
+	tm := timer.NewAdaptive(
+		time.Second,
+		timer.WithCeiling(time.Duration(20)*time.Millisecond),
+		timer.WithFloor(time.Duration(10)*time.Millisecond),
+		timer.WithWarmup(1),
+	)
+	require.NoError(t, tm.Run(func() error { return nil }))
+	assert.Equal(t, time.Duration(10)*time.Millisecond, tm.Timeout())
+
+	tm = timer.NewAdaptive(
+		time.Duration(50)*time.Millisecond,
+		timer.WithCeiling(time.Duration(20)*time.Millisecond),
+		timer.WithWarmup(1),
+	)
+	require.NoError(t, tm.Run(func() error {
+		time.Sleep(time.Duration(30) * time.Millisecond)
+		return nil
+	}))
+	assert.Equal(t, time.Duration(20)*time.Millisecond, tm.Timeout())
+
+	// Confirm a static or nil timer reports its duration
+	assert.Equal(t, time.Second, timer.New(time.Second).Timeout())
+	var nilTimer *timer.Timer
+	assert.Equal(t, time.Duration(0), nilTimer.Timeout())
 }